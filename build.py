@@ -38,24 +38,36 @@ def reset_and_patch_files(path, *args):
     for pattern, replacement in args:
         patch_file(path, pattern, replacement)
 
+# geth_dep.go and tracevm.go are written against hooks and core api of this release
+GETH_VERSION = 'v1.17.7'
+
 def build_geth(root):
     os.chdir(root + '/go-ethereum')
 
+    popen(['git', 'fetch', '--depth', '1', 'origin', 'tag', GETH_VERSION])
+    # go.mod and go.sum are changed by previous build, so checkout is forced
+    popen(['git', 'checkout', '--force', GETH_VERSION])
+
     shutil.rmtree('eth/tracers/live/dep_tracer',  ignore_errors=True)
     rmfile('eth/tracers/live/geth_dep.go')
+    shutil.rmtree('cmd/tracevm', ignore_errors=True)
 
     mkdir('eth/tracers/live/dep_tracer')
     for path in glob.iglob('../tracer/dep_tracer/*.go'):
         shutil.copy(path, 'eth/tracers/live/dep_tracer/')
     shutil.copy('../tracer/extra/geth_dep.go', 'eth/tracers/live/geth_dep.go')
+    mkdir('cmd/tracevm')
+    shutil.copy('../tracer/extra/tracevm.go', 'cmd/tracevm/main.go')
 
     popen(['go', 'get', 'github.com/basho/riak-go-client'])
     popen(['make', 'geth'])
+    popen(['go', 'build', '-o', 'build/bin/tracevm', './cmd/tracevm'])
 
     os.chdir('..')
     mkdir('build')
 
     shutil.copy('go-ethereum/build/bin/geth', 'build/geth')
+    shutil.copy('go-ethereum/build/bin/tracevm', 'build/tracevm')
     shutil.copy('conf_examples/default.json', 'build/conf.json')
     shutil.copy('tracer/extra/geth_run.py', 'build/run.py')
 
@@ -76,6 +88,13 @@ def build_lib(root):
     shutil.move('libdep.a', '../build')
     shutil.move('libdep.h', '../build')
 
+def build_replay(root):
+    os.chdir(root + '/tracer')
+
+    mkdir('../build')
+
+    popen(['go', 'build', '-o', '../build/replay', './cmd/replay'])
+
 def build_foundry(root):
     build_lib(root)
 
@@ -128,12 +147,12 @@ def build_foundry(root):
             (
                 '\n'
                 '    fn step(&mut self, interp: &mut Interpreter, context: &mut EvmContext<DB>) {\n'
-                '        tracevm::dep_step(&mut self.dep_data, interp, context);\n'
-                '        unsafe {\n'
-                '            let data = tracevm::TRACE_CALLBACK_DATA.pull();\n'
-                '            if data.is_some() {\n'
-                '                self.log(context, &data.unwrap());\n'
-                '            }\n'
+                '        if let Err(err) = tracevm::dep_step(&mut self.dep_data, interp, context) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
+                '        let data = self.dep_data.pull();\n'
+                '        if data.is_some() {\n'
+                '            self.log(context, &data.unwrap());\n'
                 '        }\n'
             ),
         ), (
@@ -141,35 +160,45 @@ def build_foundry(root):
             (
                 '\n'
                 '    fn step_end(&mut self, interp: &mut Interpreter, context: &mut EvmContext<DB>) {\n'
-                '        tracevm::dep_step_end(&mut self.dep_data, interp, context);\n'
+                '        if let Err(err) = tracevm::dep_step_end(&mut self.dep_data, interp, context) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn\s*call\s*\(\s*&mut\s*self\s*,\s*context\s*:\s*&mut\s*EvmContext<DB>\s*,\s*inputs\s*:\s*&mut\s*CallInputs\s*,\s*\)\s*->\s*Option<CallOutcome>\s*{\n',
             (
                 '\n'
                 '    fn call(&mut self, context: &mut EvmContext<DB>, inputs: &mut CallInputs) -> Option<CallOutcome> {\n'
-                '        tracevm::dep_call(&mut self.dep_data, context, inputs);\n'
+                '        if let Err(err) = tracevm::dep_call(&mut self.dep_data, context, inputs) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn\s*call_end\s*\(\s*&mut\s*self\s*,\s*context\s*:\s*&mut\s*EvmContext<DB>\s*,\s*inputs\s*:\s*&CallInputs\s*,\s*outcome\s*:\s*CallOutcome\s*,\s*\)\s*->\s*CallOutcome\s*{\n',
             (
                 '\n'
                 '    fn call_end(&mut self, context: &mut EvmContext<DB>, inputs: &CallInputs, outcome: CallOutcome) -> CallOutcome {\n'
-                '        tracevm::dep_call_end(&mut self.dep_data, context, inputs, &outcome);\n'
+                '        if let Err(err) = tracevm::dep_call_end(&mut self.dep_data, context, inputs, &outcome) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn\s*create\(\s*&mut\s*self\s*,\s*context\s*:\s*&mut\s*EvmContext<DB>\s*,\s*inputs\s*:\s*&mut\s*CreateInputs\s*,\s*\)\s*->\s*Option<CreateOutcome>\s*{\n',
             (
                 '\n'
                 '    fn create(&mut self, context: &mut EvmContext<DB>, inputs: &mut CreateInputs, ) -> Option<CreateOutcome> {\n'
-                '        tracevm::dep_create(&mut self.dep_data, context, inputs);\n'
+                '        if let Err(err) = tracevm::dep_create(&mut self.dep_data, context, inputs) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn\s*create_end\s*\(\s*&mut\s*self\s*,\s*context\s*:\s*&mut\s*EvmContext<DB>\s*,\s*inputs\s*:\s*&CreateInputs\s*,\s*outcome\s*:\s*CreateOutcome\s*,\s*\)\s*->\s*CreateOutcome\s*{\n',
             (
                 '\n'
                 '    fn create_end(&mut self, context: &mut EvmContext<DB>, inputs: &CreateInputs, outcome: CreateOutcome) -> CreateOutcome {\n'
-                '        tracevm::dep_create_end(&mut self.dep_data, context, inputs, &outcome);\n'
+                '        if let Err(err) = tracevm::dep_create_end(&mut self.dep_data, context, inputs, &outcome) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             )
         ),
     )
@@ -254,39 +283,51 @@ def build_foundry(root):
             (
                 '\n'
                 '    fn step_end(&mut self, interp: &mut Interpreter, ecx: &mut EvmContext<DB>) {\n'
-                '        tracevm::dep_step_end(&mut self.dep_data, interp, ecx);\n'
+                '        if let Err(err) = tracevm::dep_step_end(&mut self.dep_data, interp, ecx) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
                 '    }\n'
                 '\n'
                 '    fn step(&mut self, interp: &mut Interpreter, ecx: &mut EvmContext<DB>) {\n'
-                '        tracevm::dep_step(&mut self.dep_data, interp, ecx);\n'
+                '        if let Err(err) = tracevm::dep_step(&mut self.dep_data, interp, ecx) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn call\(\s*&mut self\,\s*ecx\: &mut EvmContext<DB>\,\s*inputs\: &mut CallInputs\,?\s*\)\s*\->\s*Option<CallOutcome>\s*\{\n',
             (
                 '\n'
                 '    fn call(&mut self, ecx: &mut EvmContext<DB>, inputs: &mut CallInputs) -> Option<CallOutcome> {\n'
-                '        tracevm::dep_call(&mut self.dep_data, ecx, inputs);\n'
+                '        if let Err(err) = tracevm::dep_call(&mut self.dep_data, ecx, inputs) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn call_end\(\s*&mut self\,\s*_context\: &mut EvmContext<DB>\,\s*_inputs\: &CallInputs\,\s*outcome\: CallOutcome\,\s*\)\s*\->\s*CallOutcome\s*\{\n',
             (
                 '\n'
                 '    fn call_end( &mut self, _context: &mut EvmContext<DB>, _inputs: &CallInputs, outcome: CallOutcome) -> CallOutcome {\n'
-                '        tracevm::dep_call_end(&mut self.dep_data, _context, _inputs, &outcome);\n'
+                '        if let Err(err) = tracevm::dep_call_end(&mut self.dep_data, _context, _inputs, &outcome) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn create\(\s*&mut self\,\s*ecx\: &mut EvmContext<DB>\,\s*inputs\: &mut CreateInputs\,?\s*\)\s*\->\s*Option<CreateOutcome>\s*\{\n',
             (
                 '\n'
                 '    fn create( &mut self, ecx: &mut EvmContext<DB>, inputs: &mut CreateInputs) -> Option<CreateOutcome> {\n'
-                '        tracevm::dep_create(&mut self.dep_data, ecx, inputs);\n'
+                '        if let Err(err) = tracevm::dep_create(&mut self.dep_data, ecx, inputs) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ), (
             r'\n    fn create_end\(\s*&mut self\,\s*_context\: &mut EvmContext<DB>\,\s*_inputs\: &CreateInputs\,\s*outcome\: CreateOutcome\,?\s*\)\s*\->\s*CreateOutcome\s*\{\n',
             (
                 '\n'
                 '    fn create_end( &mut self, _context: &mut EvmContext<DB>, _inputs: &CreateInputs, outcome: CreateOutcome) -> CreateOutcome {\n'
-                '        tracevm::dep_create_end(&mut self.dep_data, _context, _inputs, &outcome);\n'
+                '        if let Err(err) = tracevm::dep_create_end(&mut self.dep_data, _context, _inputs, &outcome) {\n'
+                '            eprintln!("{}", err);\n'
+                '        }\n'
             ),
         ),
     )
@@ -312,9 +353,11 @@ def main(target):
         build_lib(root)
     elif target == 'foundry':
         build_foundry(root)
+    elif target == 'replay':
+        build_replay(root)
     else:
         print('Unknown target:', target)
-        print('Targets: all, geth, lib, foundry')
+        print('Targets: all, geth, lib, foundry, replay')
         exit(1)
 
 if __name__ == '__main__':
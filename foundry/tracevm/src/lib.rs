@@ -1,4 +1,4 @@
-use std::{ ffi::{CString, c_char} };
+use std::{ ffi::{CStr, CString, c_char, c_void}, fmt, sync::{Arc, Mutex, atomic::{AtomicBool, Ordering}} };
 use queues::{ Queue, IsQueue, queue };
 use revm::{
     EvmContext, Database,
@@ -58,25 +58,57 @@ fn stack_to_cstack(stack: &Vec<U256>) -> CStack {
 }
 
 extern "C" {
-    fn RegisterGetNonce(ptr: extern "C" fn(CAddress) -> u64);
-    fn RegisterGetCode(ptr: extern "C" fn(CAddress) -> CSizedArray);
+    fn RegisterGetNonce(handle: u64, ptr: extern "C" fn(*mut c_void, CAddress) -> u64) -> i32;
+    fn RegisterGetCode(handle: u64, ptr: extern "C" fn(*mut c_void, CAddress) -> CSizedArray) -> i32;
 
-    fn InitDep(cfg: *const i8, ptr: Option<extern "C" fn(*const c_char)>);
+    fn InitDep(cfg: *const i8, ptr: Option<extern "C" fn(*mut c_void, *const c_char)>, user_data: *mut c_void) -> u64;
+    fn FreeDep(handle: u64) -> i32;
+    fn DepLastError(handle: u64) -> *const c_char;
 
     fn StartTransactionRecording(
+        handle: u64,
         isCreate: bool, addr: CAddress, input: CSizedArray, block: u64,
         timestamp: u64, origin: CAddress, txHash: CHash,
         code: CSizedArray, isSelfdestruct6780: bool, isRandom: bool,
-    );
-    fn EndTransactionRecording();
+    ) -> i32;
+    fn EndTransactionRecording(handle: u64) -> i32;
 
     fn HandleOpcode(
+        handle: u64,
         stack: CStack, memory: CSizedArray, addr: CAddress,
         pc: u64, op: u8, isInvalid: bool, hasError: bool,
-    );
-    fn HandleEnter(to: CAddress, input: CSizedArray);
-    fn HandleExit(output: CSizedArray, hasError: bool);
-    fn HandleFault(op: u8);
+    ) -> i32;
+    fn HandleEnter(handle: u64, to: CAddress, input: CSizedArray) -> i32;
+    fn HandleExit(handle: u64, output: CSizedArray, hasError: bool) -> i32;
+    fn HandleFault(handle: u64, op: u8) -> i32;
+}
+
+// panic inside of libdep, it is recovered there and returned as error code
+#[derive(Clone, Debug)]
+pub struct DepError {
+    pub message: String,
+}
+impl fmt::Display for DepError {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "tracevm: {}", self.message)
+    }
+}
+impl std::error::Error for DepError {}
+
+fn dep_result(handle: u64, code: i32) -> Result<(), DepError> {
+    if code == 0 {
+        return Ok(());
+    }
+    let message: String;
+    unsafe {
+        let err = DepLastError(handle);
+        if err.is_null() {
+            message = "unknown error".to_string();
+        } else {
+            message = CStr::from_ptr(err).to_string_lossy().into_owned();
+        }
+    }
+    Err(DepError{ message: message })
 }
 
 #[repr(u8)]
@@ -85,35 +117,77 @@ pub enum DepDataType {
     Trace = 2,
 }
 
+// state of one libdep instance, its address is user data of callbacks,
+// instance is freed when the last clone of DepData is dropped
+pub struct DepState {
+    handle: u64,
+    activated_hash: Mutex<Option<FixedBytes<32>>>,
+    get_nonce: Mutex<(Address, u64)>,
+    get_code: Mutex<(Address, Bytes)>,
+    trace_callback_data: Mutex<TraceCallbackData>,
+}
+impl fmt::Debug for DepState {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_struct("DepState").field("handle", &self.handle).finish()
+    }
+}
+impl Drop for DepState {
+    fn drop(&mut self) {
+        if self.handle != 0 {
+            unsafe {
+                let _ = FreeDep(self.handle);
+            }
+        }
+    }
+}
+
 #[derive(Clone, Debug)]
 pub struct DepData<const DATA_TYPE: u8> {
     pub call_depth: i32,
     pub activated: bool,
+    state: Arc<DepState>,
 }
 impl<const DATA_TYPE: u8> DepData<DATA_TYPE> {
     pub fn clear(&mut self) {
         panic!("I don't know what to do with this");
     }
-}
-impl<const DATA_TYPE: u8> Default for DepData<DATA_TYPE> {
-    fn default() -> DepData<DATA_TYPE> {
-        unsafe {
-            // easy fix
-            if DEP_DATA_TYPE != 0 {
-                if DEP_DATA_TYPE == DepDataType::Debug as u8 && DATA_TYPE == DepDataType::Trace as u8 {
-                    return DepData {
-                        call_depth: 0,
-                        activated: false,
-                    }
-                } else {
-                    panic!("Unknown DEP_DATA_TYPEs: {}, {}", DEP_DATA_TYPE, DATA_TYPE);
-                }
+    // transaction hash reported by this instance, overrides hash of activate
+    pub fn activate(&self, hash: FixedBytes<32>) {
+        *self.state.activated_hash.lock().unwrap() = Some(hash);
+    }
+    fn activated_hash(&self) -> FixedBytes<32> {
+        match *self.state.activated_hash.lock().unwrap() {
+            Some(hash) => hash,
+            None => *DEFAULT_ACTIVATED_HASH.lock().unwrap(),
+        }
+    }
+    pub fn pull(&self) -> Option<Log> {
+        self.state.trace_callback_data.lock().unwrap().pull()
+    }
+    fn inactive() -> DepData<DATA_TYPE> {
+        DepData {
+            call_depth: 0,
+            activated: false,
+            state: Arc::new(DepState::new(0)),
+        }
+    }
+    // after error libdep drops the current transaction, the next one is traced
+    fn check(&self, code: i32) -> Result<(), DepError> {
+        dep_result(self.state.handle, code)
+    }
+    pub fn new() -> Result<DepData<DATA_TYPE>, DepError> {
+        // webview of debug is served on fixed port, so it can be only one,
+        // trace instances are created per inspector (tests run in parallel)
+        if DATA_TYPE == DepDataType::Debug as u8 {
+            if DEBUG_CREATED.swap(true, Ordering::SeqCst) {
+                panic!("Debug DepData created twice");
             }
-            DEP_DATA_TYPE = DATA_TYPE;
+        } else if DEBUG_CREATED.load(Ordering::SeqCst) {
+            return Ok(DepData::inactive())
         }
 
         let cfg: &str;
-        let callback: Option<extern "C" fn(*const c_char)>;
+        let callback: Option<extern "C" fn(*mut c_void, *const c_char)>;
 
         match DATA_TYPE {
             1_u8 => {
@@ -169,60 +243,91 @@ impl<const DATA_TYPE: u8> Default for DepData<DATA_TYPE> {
             _ => panic!("Unknown DATA_TYPE")
         }
 
+        // state is allocated before InitDep, since its address is passed as user data
+        let mut state = Arc::new(DepState::new(0));
+        let user_data = Arc::as_ptr(&state) as *mut c_void;
         let ccfg = CString::new(cfg).expect("CString::new failed");
+        let handle: u64;
         unsafe {
-            InitDep(ccfg.as_ptr(), callback);
-            RegisterGetNonce(get_nonce);
-            RegisterGetCode(get_code);
+            handle = InitDep(ccfg.as_ptr(), callback, user_data);
         }
-        DepData {
+        if handle == 0 {
+            dep_result(0, 1)?;
+        }
+        Arc::get_mut(&mut state).unwrap().handle = handle;
+        unsafe {
+            dep_result(handle, RegisterGetNonce(handle, get_nonce))?;
+            dep_result(handle, RegisterGetCode(handle, get_code))?;
+        }
+        Ok(DepData {
             call_depth: 0,
             activated: true,
+            state: state,
+        })
+    }
+}
+impl<const DATA_TYPE: u8> Default for DepData<DATA_TYPE> {
+    // inspectors are created with default, so error only disables tracing
+    fn default() -> DepData<DATA_TYPE> {
+        match DepData::new() {
+            Ok(data) => data,
+            Err(err) => {
+                eprintln!("{}", err);
+                DepData::inactive()
+            }
         }
     }
 }
 
+impl DepState {
+    fn new(handle: u64) -> DepState {
+        DepState {
+            handle: handle,
+            activated_hash: Mutex::new(None),
+            get_nonce: Mutex::new((ZERO_ADDRESS, 0)),
+            get_code: Mutex::new((ZERO_ADDRESS, Bytes::new())),
+            trace_callback_data: Mutex::new(TraceCallbackData{ queue: None }),
+        }
+    }
+}
+
+fn user_data_to_state<'a>(user_data: *mut c_void) -> &'a DepState {
+    unsafe {
+        &*(user_data as *const DepState)
+    }
+}
+
 static ZERO_ADDRESS: Address = address!("0000000000000000000000000000000000000000");
 
 extern "C"
-fn get_nonce(_addr: CAddress) -> u64 {
-    unsafe {
-        if GET_NONCE_ADDRESS == ZERO_ADDRESS {
-            panic!("GET_NONCE_ADDRESS is zero")
-        }
-        GET_NONCE_ADDRESS = ZERO_ADDRESS;
-        GET_NONCE_NONCE
+fn get_nonce(user_data: *mut c_void, _addr: CAddress) -> u64 {
+    let mut get_nonce = user_data_to_state(user_data).get_nonce.lock().unwrap();
+    if get_nonce.0 == ZERO_ADDRESS {
+        panic!("get_nonce address is zero")
     }
+    get_nonce.0 = ZERO_ADDRESS;
+    get_nonce.1
 }
-static mut GET_NONCE_ADDRESS: Address = ZERO_ADDRESS;
-static mut GET_NONCE_NONCE:   u64     = 0;
 
+// code stays in state after the call, libdep copies it
 extern "C"
-fn get_code(_addr: CAddress) -> CSizedArray {
-    unsafe {
-        if GET_CODE_ADDRESS == ZERO_ADDRESS {
-            panic!("GET_CODE_ADDRESS is zero")
-        }
-        GET_CODE_ADDRESS = ZERO_ADDRESS;
-        CSizedArray{
-            data: GET_CODE_DATA.as_ptr(),
-            size: GET_CODE_DATA.len() as i32,
-        }
+fn get_code(user_data: *mut c_void, _addr: CAddress) -> CSizedArray {
+    let mut get_code = user_data_to_state(user_data).get_code.lock().unwrap();
+    if get_code.0 == ZERO_ADDRESS {
+        panic!("get_code address is zero")
     }
+    get_code.0 = ZERO_ADDRESS;
+    bytes_to_csizedarray(&get_code.1)
 }
-static mut GET_CODE_ADDRESS: Address = ZERO_ADDRESS;
-static mut GET_CODE_DATA:    Bytes   = Bytes::new();
 
 extern "C"
-fn trace_callback(data: *const c_char) {
+fn trace_callback(user_data: *mut c_void, data: *const c_char) {
     let c_str: &std::ffi::CStr;
     unsafe {
         c_str = std::ffi::CStr::from_ptr(data);
     }
     let str_slice: &str = c_str.to_str().unwrap();
-    unsafe {
-        TRACE_CALLBACK_DATA.push(str_slice);
-    }
+    user_data_to_state(user_data).trace_callback_data.lock().unwrap().push(str_slice);
 }
 sol! {
     #[derive(Default, PartialEq, Debug)]
@@ -319,31 +424,26 @@ impl TraceCallbackData {
         Some(log)
     }
 }
-pub static mut TRACE_CALLBACK_DATA: TraceCallbackData = TraceCallbackData{
-    queue: None,
-};
-
-static mut ACTIVATED_HASH: FixedBytes<32> = FixedBytes::ZERO;
-static mut DEP_DATA_TYPE: u8 = 0;
+// hash of instances which were not activated explicitly (cast run traces one transaction)
+static DEFAULT_ACTIVATED_HASH: Mutex<FixedBytes<32>> = Mutex::new(FixedBytes::ZERO);
+static DEBUG_CREATED: AtomicBool = AtomicBool::new(false);
 fn is_activated<const DATA_TYPE: u8>(data: &DepData<DATA_TYPE>) -> bool {
     if !data.activated {
         return false;
     }
-    unsafe {
-        return ACTIVATED_HASH != FixedBytes::ZERO;
-    }
+    data.activated_hash() != FixedBytes::ZERO
 }
 pub fn activate(hash: FixedBytes<32>) {
-    unsafe {
-        ACTIVATED_HASH = hash;
-    }
+    *DEFAULT_ACTIVATED_HASH.lock().unwrap() = hash;
 }
 
-fn on_enter<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, is_create: bool, input: &Bytes, addr: Address) {
+fn on_enter<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, is_create: bool, input: &Bytes, addr: Address) -> Result<(), DepError> {
     if !is_activated(data) {
-        return;
+        return Ok(());
     }
 
+    // depth is counted even after error, libdep skips the rest of the transaction
+    let mut start_res = Ok(());
     if data.call_depth == 0 {
         let input = context.inner.env.tx.data.clone();
         let origin = context.inner.env.tx.caller;
@@ -361,59 +461,69 @@ fn on_enter<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, con
         let timestamp = context.inner.env.block.timestamp;
         let is_selfdestruct6780 = SpecId::enabled(context.inner.journaled_state.spec, SpecId::CANCUN);
         let is_random = context.inner.env.block.prevrandao.is_some();
+        let tx_hash = data.activated_hash();
 
-        unsafe {
+        let res = unsafe {
             StartTransactionRecording(
+                data.state.handle,
                 is_create,
                 address_to_caddress(addr),
                 bytes_to_csizedarray(&input),
                 block.to::<u64>(),
                 timestamp.to::<u64>(),
                 address_to_caddress(origin),
-                CHash{ data: *ACTIVATED_HASH },
+                CHash{ data: *tx_hash },
                 bytes_to_csizedarray(&code),
                 is_selfdestruct6780,
                 is_random,
-            );
-        }
+            )
+        };
+        start_res = data.check(res);
     }
 
-    unsafe {
+    let res = unsafe {
         HandleEnter(
+            data.state.handle,
             address_to_caddress(addr),
             bytes_to_csizedarray(&input),
         )
-    }
+    };
 
     data.call_depth += 1;
+    start_res.and(data.check(res))
 }
 
-fn on_exit<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, result: &InterpreterResult) {
+fn on_exit<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, result: &InterpreterResult) -> Result<(), DepError> {
     if !is_activated(data) {
-        return;
+        return Ok(());
     }
 
     data.call_depth -= 1;
 
-    unsafe {
+    let res = unsafe {
         HandleExit(
+            data.state.handle,
             bytes_to_csizedarray(&result.output),
             context.inner.error.is_err(),
         )
-    }
+    };
+    data.check(res)?;
 
     if data.call_depth == 0 {
-        unsafe {
-            EndTransactionRecording();
-        }
+        let res = unsafe {
+            EndTransactionRecording(data.state.handle)
+        };
+        data.check(res)?;
     }
+    Ok(())
 }
 
-pub fn dep_step<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, interp: &mut Interpreter, context: &mut EvmContext<DB>) {
+pub fn dep_step<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, interp: &mut Interpreter, context: &mut EvmContext<DB>) -> Result<(), DepError> {
     if !is_activated(data) {
-        return;
+        return Ok(());
     }
 
+    let state = data.state.clone();
     let is_invalid: bool;
     if let Some(op) = OpCode::new(interp.current_opcode()) {
         is_invalid = false;
@@ -428,10 +538,7 @@ pub fn dep_step<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>,
                         Err(_) => panic!("context.inner.code(addr) failed"),
                     };
                     let data = bytecode.bytecode().clone();
-                    unsafe {
-                        GET_CODE_ADDRESS = addr;
-                        GET_CODE_DATA = data;
-                    }
+                    *state.get_code.lock().unwrap() = (addr, data);
                 }
             },
             OpCode::CALL | OpCode::CALLCODE | OpCode::DELEGATECALL | OpCode::STATICCALL=> {
@@ -444,20 +551,14 @@ pub fn dep_step<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>,
                         Err(_) => panic!("context.inner.code(addr) failed"),
                     };
                     let data = bytecode.bytecode().clone();
-                    unsafe {
-                        GET_CODE_ADDRESS = addr;
-                        GET_CODE_DATA = data;
-                    }
+                    *state.get_code.lock().unwrap() = (addr, data);
                 }
             },
             OpCode::CREATE => {
                 let address = interp.contract.target_address;
                 let nonce = context.journaled_state.account(address).info.nonce;
 
-                unsafe {
-                    GET_NONCE_ADDRESS = address;
-                    GET_NONCE_NONCE = nonce;
-                }
+                *state.get_nonce.lock().unwrap() = (address, nonce);
             },
             _ => (),
         }
@@ -468,8 +569,9 @@ pub fn dep_step<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>,
     let mut mem_copy = vec![0; interp.shared_memory.len()];
     mem_copy.clone_from_slice(interp.shared_memory.context_memory());
 
-    unsafe {
+    let res = unsafe {
         HandleOpcode(
+            data.state.handle,
             stack_to_cstack(interp.stack.data()),
             bytes_to_csizedarray(&mem_copy.into()),
             address_to_caddress(interp.contract.target_address),
@@ -477,36 +579,39 @@ pub fn dep_step<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>,
             interp.current_opcode(),
             is_invalid,
             context.inner.error.is_err(),
-        );
-    }
+        )
+    };
+    data.check(res)
 }
 
-pub fn dep_step_end<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, interp: &mut Interpreter, context: &mut EvmContext<DB>) {
+pub fn dep_step_end<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, interp: &mut Interpreter, context: &mut EvmContext<DB>) -> Result<(), DepError> {
     if !is_activated(data) {
-        return;
+        return Ok(());
     }
 
     if context.inner.error.is_err() {
-        unsafe {
-            HandleFault(interp.current_opcode())
-        }
+        let res = unsafe {
+            HandleFault(data.state.handle, interp.current_opcode())
+        };
+        data.check(res)?;
     }
+    Ok(())
 }
 
-pub fn dep_call<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, inputs: &mut CallInputs) {
+pub fn dep_call<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, inputs: &mut CallInputs) -> Result<(), DepError> {
     let addr = inputs.target_address;
     on_enter(data, context, false, &inputs.input, addr)
 }
 
-pub fn dep_call_end<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, _inputs: &CallInputs, outcome: &CallOutcome) {
+pub fn dep_call_end<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, _inputs: &CallInputs, outcome: &CallOutcome) -> Result<(), DepError> {
     on_exit(data, context, &outcome.result)
 }
 
-pub fn dep_create<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, inputs: &mut CreateInputs) {
+pub fn dep_create<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, inputs: &mut CreateInputs) -> Result<(), DepError> {
     let addr = inputs.created_address(context.journaled_state.account(inputs.caller).info.nonce);
     on_enter(data, context, true, &inputs.init_code, addr)
 }
 
-pub fn dep_create_end<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, _inputs: &CreateInputs, outcome: &CreateOutcome) {
+pub fn dep_create_end<DB:Database, const DATA_TYPE: u8>(data: &mut DepData<DATA_TYPE>, context: &mut EvmContext<DB>, _inputs: &CreateInputs, outcome: &CreateOutcome) -> Result<(), DepError> {
     on_exit(data, context, &outcome.result)
 }
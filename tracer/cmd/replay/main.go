@@ -0,0 +1,45 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "encoding/json"
+
+    "dep_tracer/dep_tracer"
+)
+
+// replays input events recorded by "record" option of config:
+// replay <config.json> <recorded events file>
+func main() {
+    if len(os.Args) != 3 {
+        fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "<config.json> <recorded events file>")
+        os.Exit(1)
+    }
+
+    cfg, err := os.ReadFile(os.Args[1])
+    if err != nil {
+        panic(err)
+    }
+    // recorder would truncate the file (possibly the one being replayed), replay is not recorded
+    config := map[string]json.RawMessage{}
+    if err := json.Unmarshal(cfg, &config); err != nil {
+        panic(fmt.Errorf("failed to parse config: %v", err))
+    }
+    if _, ok := config["record"]; ok {
+        fmt.Fprintln(os.Stderr, "record option is ignored by replay")
+        delete(config, "record")
+    }
+    cfg, err = json.Marshal(config)
+    if err != nil {
+        panic(err)
+    }
+    f, err := os.Open(os.Args[2])
+    if err != nil {
+        panic(err)
+    }
+    defer f.Close()
+
+    handler := dep_tracer.NewDepHandler(cfg, nil)
+    defer handler.Close()
+    dep_tracer.Replay(handler, f)
+}
@@ -0,0 +1,295 @@
+package dep_tracer
+
+import (
+    "os"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+    "path/filepath"
+    "encoding/json"
+    "github.com/holiman/uint256"
+)
+
+type AbiParam struct {
+    Name       string     `json:"name"`
+    Type       string     `json:"type"`
+    Indexed    bool       `json:"indexed"`
+    Components []AbiParam `json:"components"`
+}
+
+type AbiEntry struct {
+    Type      string     `json:"type"`
+    Name      string     `json:"name"`
+    Inputs    []AbiParam `json:"inputs"`
+    Anonymous bool       `json:"anonymous"`
+}
+
+func (e *AbiEntry) Signature() string {
+    types := []string{}
+    for _, input := range e.Inputs {
+        types = append(types, input.canonicalType())
+    }
+    return e.Name + "(" + strings.Join(types, ",") + ")"
+}
+
+func (p *AbiParam) canonicalType() string {
+    if !strings.HasPrefix(p.Type, "tuple") {
+        return p.Type
+    }
+    types := []string{}
+    for _, component := range p.Components {
+        types = append(types, component.canonicalType())
+    }
+    return "(" + strings.Join(types, ",") + ")" + p.Type[len("tuple"):]
+}
+
+// checked when abi is loaded, so that decoding during tracing does not fail on a type
+func (p *AbiParam) validType() bool {
+    typ := p.Type
+    if strings.HasSuffix(typ, "]") {
+        i := strings.LastIndex(typ, "[")
+        if i < 0 {
+            return false
+        }
+        if dim := typ[i+1:len(typ)-1]; dim != "" {
+            if _, err := strconv.ParseUint(dim, 10, 64); err != nil {
+                return false
+            }
+        }
+        elem := AbiParam{Type: typ[:i], Components: p.Components}
+        return elem.validType()
+    }
+    bits := func(prefix string, min, max, step uint64) bool {
+        n, err := strconv.ParseUint(strings.TrimPrefix(typ, prefix), 10, 64)
+        return err == nil && n >= min && n <= max && n % step == 0
+    }
+    switch {
+    case typ == "address" || typ == "bool" || typ == "bytes" || typ == "string" || typ == "function":
+        return true
+    case typ == "tuple":
+        for _, component := range p.Components {
+            if !component.validType() {
+                return false
+            }
+        }
+        return true
+    case strings.HasPrefix(typ, "uint"):
+        return bits("uint", 8, 256, 8)
+    case strings.HasPrefix(typ, "int"):
+        return bits("int", 8, 256, 8)
+    case strings.HasPrefix(typ, "bytes"):
+        return bits("bytes", 1, 32, 1)
+    default:
+        return false
+    }
+}
+
+// returns static size of encoded param, 0 if param is dynamic
+func (p *AbiParam) staticSize() uint64 {
+    typ := p.Type
+    if strings.HasSuffix(typ, "]") {
+        i := strings.LastIndex(typ, "[")
+        dim := typ[i+1:len(typ)-1]
+        if dim == "" {
+            return 0
+        }
+        n, err := strconv.ParseUint(dim, 10, 64)
+        if err != nil {
+            panic(fmt.Errorf("invalid abi type %s", typ))
+        }
+        elem := AbiParam{Type: typ[:i], Components: p.Components}
+        size := elem.staticSize()
+        // huge arrays saturate, they never fit into data
+        if size != 0 && n > math.MaxUint64 / size {
+            return math.MaxUint64
+        }
+        return n * size
+    }
+    switch typ {
+    case "bytes", "string":
+        return 0
+    case "tuple":
+        res := uint64(0)
+        for _, component := range p.Components {
+            size := component.staticSize()
+            if size == 0 {
+                return 0
+            }
+            if res > math.MaxUint64 - size {
+                return math.MaxUint64
+            }
+            res += size
+        }
+        return res
+    default:
+        return 32
+    }
+}
+
+type AbiRange struct {
+    Name   string
+    Type   string
+    Offset uint64
+    Size   uint64
+}
+
+// splits abi encoded data into ranges of params, false if data is malformed
+func abiDecodeRanges(params []AbiParam, data []byte) ([]AbiRange, bool) {
+    // offsets come from untrusted data, so checks are written without overflow
+    dataLen := uint64(len(data))
+    readWord := func(offset uint64) (uint64, bool) {
+        if offset > dataLen || dataLen - offset < 32 {
+            return 0, false
+        }
+        val := new(uint256.Int).SetBytes(data[offset:offset+32])
+        if !val.IsUint64() {
+            return 0, false
+        }
+        return val.Uint64(), true
+    }
+
+    res := []AbiRange{}
+    dynamic := []int{}
+    head := uint64(0)
+    for _, param := range params {
+        size := param.staticSize()
+        if size > 0 {
+            if head > dataLen || size > dataLen - head {
+                return nil, false
+            }
+            res = append(res, AbiRange{param.Name, param.canonicalType(), head, size})
+            head += size
+            continue
+        }
+        offset, ok := readWord(head)
+        if !ok {
+            return nil, false
+        }
+        res = append(res, AbiRange{param.Name, param.canonicalType(), offset, 0})
+        dynamic = append(dynamic, len(res)-1)
+        head += 32
+    }
+    if head > dataLen {
+        return nil, false
+    }
+    for i, j := range dynamic {
+        r := &res[j]
+        if r.Offset > dataLen {
+            return nil, false
+        }
+        if r.Type == "bytes" || r.Type == "string" {
+            length, ok := readWord(r.Offset)
+            if !ok {
+                return nil, false
+            }
+            r.Offset += 32
+            r.Size = length
+        } else if i + 1 < len(dynamic) && res[dynamic[i+1]].Offset >= r.Offset {
+            r.Size = res[dynamic[i+1]].Offset - r.Offset
+        } else {
+            r.Size = dataLen - r.Offset
+        }
+        if r.Offset > dataLen || r.Size > dataLen - r.Offset {
+            return nil, false
+        }
+    }
+    return res, true
+}
+
+type AbiRegistry struct {
+    events    map[Hash]AbiEntry
+    functions map[[4]byte]AbiEntry
+}
+
+func NewAbiRegistry(paths []string) *AbiRegistry {
+    r := new(AbiRegistry)
+    r.events = make(map[Hash]AbiEntry)
+    r.functions = make(map[[4]byte]AbiEntry)
+    for _, path := range paths {
+        info, err := os.Stat(path)
+        if err != nil {
+            panic(err)
+        }
+        if !info.IsDir() {
+            r.LoadFile(path)
+            continue
+        }
+        files, err := filepath.Glob(filepath.Join(path, "*.json"))
+        if err != nil {
+            panic(err)
+        }
+        for _, file := range files {
+            r.LoadFile(file)
+        }
+    }
+    return r
+}
+
+// accepts both plain abi and compiler artifacts with "abi" field
+func (r *AbiRegistry) LoadFile(path string) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        panic(err)
+    }
+    var entries []AbiEntry
+    if err := json.Unmarshal(data, &entries); err != nil {
+        var artifact struct {
+            Abi []AbiEntry `json:"abi"`
+        }
+        if err := json.Unmarshal(data, &artifact); err != nil {
+            panic(fmt.Errorf("failed to parse abi %s: %v", path, err))
+        }
+        entries = artifact.Abi
+    }
+    for _, entry := range entries {
+        if entry.Type == "event" || entry.Type == "function" {
+            for _, input := range entry.Inputs {
+                if !input.validType() {
+                    panic(fmt.Errorf("unsupported abi type %s of %s in %s", input.Type, entry.Signature(), path))
+                }
+            }
+        }
+        switch entry.Type {
+        case "event":
+            if entry.Anonymous {
+                continue
+            }
+            r.events[Hash(Keccak256([]byte(entry.Signature())))] = entry
+        case "function":
+            r.functions[[4]byte(Keccak256([]byte(entry.Signature()))[:4])] = entry
+        }
+    }
+}
+
+func (r *AbiRegistry) Event(topic0 []byte) (AbiEntry, bool) {
+    if r == nil || len(topic0) != 32 {
+        return AbiEntry{}, false
+    }
+    entry, ok := r.events[Hash(topic0)]
+    return entry, ok
+}
+
+func (r *AbiRegistry) Function(calldata []byte) (AbiEntry, bool) {
+    if r == nil || len(calldata) < 4 {
+        return AbiEntry{}, false
+    }
+    entry, ok := r.functions[[4]byte(calldata[:4])]
+    return entry, ok
+}
+
+// ranges are relative to the whole calldata (including selector)
+func (r *AbiRegistry) CalldataRanges(calldata []byte) (AbiEntry, []AbiRange, bool) {
+    entry, ok := r.Function(calldata)
+    if !ok {
+        return AbiEntry{}, nil, false
+    }
+    ranges, ok := abiDecodeRanges(entry.Inputs, calldata[4:])
+    if !ok {
+        return AbiEntry{}, nil, false
+    }
+    for i := range ranges {
+        ranges[i].Offset += 4
+    }
+    return entry, ranges, true
+}
@@ -0,0 +1,120 @@
+package dep_tracer
+
+import (
+    "os"
+    "testing"
+    "path/filepath"
+    "encoding/hex"
+)
+
+func abiTestData(words ...string) []byte {
+    res := []byte{}
+    for _, word := range words {
+        b, err := hex.DecodeString(word)
+        if err != nil {
+            panic(err)
+        }
+        padded := make([]byte, 32)
+        copy(padded[32-len(b):], b)
+        res = append(res, padded...)
+    }
+    return res
+}
+
+func TestAbiDecodeRanges(t *testing.T) {
+    uintParam := AbiParam{Name: "a", Type: "uint256"}
+    bytesParam := AbiParam{Name: "b", Type: "bytes"}
+    arrayParam := AbiParam{Name: "c", Type: "uint256[]"}
+    tests := []struct {
+        name   string
+        params []AbiParam
+        data   []byte
+        ranges []AbiRange
+        ok     bool
+    }{
+        {
+            "static", []AbiParam{uintParam}, abiTestData("2a"),
+            []AbiRange{{"a", "uint256", 0, 32}}, true,
+        },
+        {
+            "bytes", []AbiParam{bytesParam}, abiTestData("20", "03", "616263"),
+            []AbiRange{{"b", "bytes", 64, 3}}, true,
+        },
+        {
+            "array is the rest of data", []AbiParam{arrayParam}, abiTestData("20", "01", "07"),
+            []AbiRange{{"c", "uint256[]", 32, 64}}, true,
+        },
+        {"empty", []AbiParam{uintParam}, []byte{}, nil, false},
+        {"truncated static", []AbiParam{uintParam, uintParam}, abiTestData("01"), nil, false},
+        {"truncated head", []AbiParam{bytesParam}, abiTestData("20")[:16], nil, false},
+        {"truncated length", []AbiParam{bytesParam}, abiTestData("20"), nil, false},
+        {"truncated bytes", []AbiParam{bytesParam}, abiTestData("20", "40"), nil, false},
+        {"offset past end", []AbiParam{bytesParam}, abiTestData("60", "00"), nil, false},
+        {"array offset past end", []AbiParam{arrayParam}, abiTestData("60", "00"), nil, false},
+        {"offset overflow", []AbiParam{bytesParam}, abiTestData("fffffffffffffff0", "00"), nil, false},
+        {"array offset overflow", []AbiParam{arrayParam}, abiTestData("fffffffffffffff0", "00"), nil, false},
+        {"length overflow", []AbiParam{bytesParam}, abiTestData("20", "ffffffffffffffff"), nil, false},
+        {"offset bigger than uint64", []AbiParam{bytesParam}, abiTestData("010000000000000000", "00"), nil, false},
+        {"static size overflow", []AbiParam{{Name: "d", Type: "uint256[576460752303423488]"}}, abiTestData("00"), nil, false},
+    }
+    for _, test := range tests {
+        ranges, ok := abiDecodeRanges(test.params, test.data)
+        if ok != test.ok {
+            t.Errorf("%s: ok is %v, expected %v", test.name, ok, test.ok)
+            continue
+        }
+        if !ok {
+            continue
+        }
+        if len(ranges) != len(test.ranges) {
+            t.Errorf("%s: %d ranges, expected %d", test.name, len(ranges), len(test.ranges))
+            continue
+        }
+        for i := range ranges {
+            if ranges[i] != test.ranges[i] {
+                t.Errorf("%s: range %d is %+v, expected %+v", test.name, i, ranges[i], test.ranges[i])
+            }
+        }
+    }
+}
+
+func TestAbiValidType(t *testing.T) {
+    tests := []struct {
+        param AbiParam
+        ok    bool
+    }{
+        {AbiParam{Type: "uint256"}, true},
+        {AbiParam{Type: "int8"}, true},
+        {AbiParam{Type: "address[]"}, true},
+        {AbiParam{Type: "bytes32[2][]"}, true},
+        {AbiParam{Type: "string"}, true},
+        {AbiParam{Type: "tuple[]", Components: []AbiParam{{Type: "bool"}, {Type: "bytes"}}}, true},
+        {AbiParam{Type: "uint7"}, false},
+        {AbiParam{Type: "uint264"}, false},
+        {AbiParam{Type: "int0"}, false},
+        {AbiParam{Type: "bytes33"}, false},
+        {AbiParam{Type: "uint256[x]"}, false},
+        {AbiParam{Type: "uint256]"}, false},
+        {AbiParam{Type: "tuple", Components: []AbiParam{{Type: "foo"}}}, false},
+        {AbiParam{Type: "mapping"}, false},
+    }
+    for _, test := range tests {
+        if ok := test.param.validType(); ok != test.ok {
+            t.Errorf("%s: valid is %v, expected %v", test.param.Type, ok, test.ok)
+        }
+    }
+}
+
+func TestAbiLoadFileRejectsUnsupportedType(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "bad.json")
+    abi := `[{"type":"event","name":"E","inputs":[{"name":"a","type":"uint256[x]"}]}]`
+    if err := os.WriteFile(path, []byte(abi), 0644); err != nil {
+        t.Fatal(err)
+    }
+    defer func() {
+        if recover() == nil {
+            t.Errorf("abi with unsupported type is loaded")
+        }
+    }()
+    NewAbiRegistry([]string{path})
+}
@@ -3,6 +3,9 @@ package dep_tracer
 type Hash    [32]byte
 type Address [20]byte
 
+// sender of system calls (EIP-4788 beacon root, EIP-2935 history, EIP-7002/7251 requests)
+var SystemAddress = Address{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}
+
 var (
     ConstantInitZero = ConstantNew(OPInitZero, []byte{0})
     ConstantZero     = ConstantNew(OPConstant, []byte{0})
@@ -40,6 +40,12 @@ func CreateAddress2(b Address, salt Hash, inithash []byte) Address {
     return bytesToAddress(Keccak256([]byte{0xff}, b[:], salt[:], inithash)[12:])
 }
 
+// EOFCREATE address does not depend on initcontainer
+func EOFCreateAddress(b Address, salt Hash) Address {
+    sender := append(make([]byte, 12), b[:]...)
+    return bytesToAddress(Keccak256([]byte{0xff}, sender, salt[:])[12:])
+}
+
 func bytesToAddress(b []byte) Address {
     setBytes := func(a *Address, b []byte) {
         if len(b) > len(a) {
@@ -0,0 +1,34 @@
+package dep_tracer
+
+import (
+    "bytes"
+)
+
+// EIP-7702 delegation designator: 0xef0100 || address
+var delegationPrefix = []byte{0xef, 0x01, 0x00}
+
+func ParseDelegation(code []byte) (Address, bool) {
+    if len(code) != len(delegationPrefix) + 20 || !bytes.HasPrefix(code, delegationPrefix) {
+        return Address{}, false
+    }
+    var res Address
+    copy(res[:], code[len(delegationPrefix):])
+    return res, true
+}
+
+// code set by authorization, zero address clears delegation
+func DelegationCode(target Address) []byte {
+    if target == (Address{}) {
+        return []byte{}
+    }
+    res := append([]byte{}, delegationPrefix...)
+    return append(res, target[:]...)
+}
+
+// delegated account executes code of delegate in its own context
+func resolveDelegation(codeAddress Address, code, delegatedCode []byte) (Address, []byte, bool) {
+    if target, ok := ParseDelegation(code); ok {
+        return target, delegatedCode, true
+    }
+    return codeAddress, code, false
+}
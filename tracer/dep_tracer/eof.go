@@ -0,0 +1,162 @@
+package dep_tracer
+
+import (
+    "encoding/binary"
+)
+
+const (
+    eofMagic          = 0xEF00
+    eofVersion        = 0x01
+    eofKindTypes      = 0x01
+    eofKindCode       = 0x02
+    eofKindContainer  = 0x03
+    eofKindData       = 0xFF
+    eofTerminator     = 0x00
+)
+
+// EOF container (EIP-3540), offsets are absolute positions inside of code
+type EOFContainer struct {
+    code             []byte
+    codeOffsets      []uint64
+    codeSizes        []uint64
+    containerOffsets []uint64
+    containerSizes   []uint64
+    dataOffset       uint64
+    dataSizePos      uint64 // position of data size in header
+}
+
+func IsEOF(code []byte) bool {
+    return len(code) >= 2 && binary.BigEndian.Uint16(code) == eofMagic
+}
+
+// returns nil if code is legacy or container is malformed
+func ParseEOF(code []byte) *EOFContainer {
+    if !IsEOF(code) || len(code) < 3 || code[2] != eofVersion {
+        return nil
+    }
+    pos := uint64(3)
+    l := uint64(len(code))
+    readUint := func(size uint64) (uint64, bool) {
+        if pos + size > l {
+            return 0, false
+        }
+        var res uint64
+        for _, b := range code[pos:pos+size] {
+            res = res << 8 | uint64(b)
+        }
+        pos += size
+        return res, true
+    }
+    readSizes := func(size uint64) ([]uint64, bool) {
+        num, ok := readUint(2)
+        if !ok || num == 0 {
+            return nil, false
+        }
+        sizes := []uint64{}
+        for i := uint64(0); i < num; i++ {
+            s, ok := readUint(size)
+            if !ok {
+                return nil, false
+            }
+            sizes = append(sizes, s)
+        }
+        return sizes, true
+    }
+
+    c := new(EOFContainer)
+    c.code = code
+
+    if kind, ok := readUint(1); !ok || kind != eofKindTypes {
+        return nil
+    }
+    typesSize, ok := readUint(2)
+    if !ok {
+        return nil
+    }
+    if kind, ok := readUint(1); !ok || kind != eofKindCode {
+        return nil
+    }
+    c.codeSizes, ok = readSizes(2)
+    if !ok {
+        return nil
+    }
+    kind, ok := readUint(1)
+    if !ok {
+        return nil
+    }
+    if kind == eofKindContainer {
+        c.containerSizes, ok = readSizes(4)
+        if !ok {
+            return nil
+        }
+        kind, ok = readUint(1)
+        if !ok {
+            return nil
+        }
+    }
+    if kind != eofKindData {
+        return nil
+    }
+    c.dataSizePos = pos
+    if _, ok := readUint(2); !ok {
+        return nil
+    }
+    if terminator, ok := readUint(1); !ok || terminator != eofTerminator {
+        return nil
+    }
+
+    offset := pos + typesSize
+    for _, size := range c.codeSizes {
+        c.codeOffsets = append(c.codeOffsets, offset)
+        offset += size
+    }
+    for _, size := range c.containerSizes {
+        c.containerOffsets = append(c.containerOffsets, offset)
+        offset += size
+    }
+    if offset > l {
+        return nil
+    }
+    c.dataOffset = offset
+    return c
+}
+
+// absolute position of pc which is relative to code section
+func (c *EOFContainer) CodeOffset(section uint16, pc uint64) uint64 {
+    if int(section) >= len(c.codeOffsets) {
+        panic("unknown eof code section")
+    }
+    return c.codeOffsets[section] + pc
+}
+
+func (c *EOFContainer) Immediate(section uint16, pc uint64, size uint64) []byte {
+    res := make([]byte, size)
+    start := c.CodeOffset(section, pc) + 1
+    if start < uint64(len(c.code)) {
+        copy(res, c.code[start:])
+    }
+    return res
+}
+
+func (c *EOFContainer) Subcontainer(index uint8) (uint64, uint64) {
+    if int(index) >= len(c.containerOffsets) {
+        panic("unknown eof subcontainer")
+    }
+    return c.containerOffsets[index], c.containerSizes[index]
+}
+
+// data section can be shorter than declared before aux data is appended
+func (c *EOFContainer) DataSize() uint64 {
+    return uint64(len(c.code)) - c.dataOffset
+}
+
+// deployed container of RETURNCONTRACT, aux data is appended to data section
+func EOFAppendData(container []byte, aux []byte) []byte {
+    c := ParseEOF(container)
+    if c == nil {
+        panic("invalid eof deploy container")
+    }
+    res := append([]byte{}, container...)
+    binary.BigEndian.PutUint16(res[c.dataSizePos:], uint16(c.DataSize() + uint64(len(aux))))
+    return append(res, aux...)
+}
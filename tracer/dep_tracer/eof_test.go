@@ -0,0 +1,171 @@
+package dep_tracer
+
+import (
+    "testing"
+    "strings"
+    "math/big"
+    "encoding/hex"
+
+    "github.com/holiman/uint256"
+)
+
+func TestParseEOF(t *testing.T) {
+    // types 4 bytes, one code section of 1 byte (STOP), data of 2 bytes
+    valid := "ef0001" + "010004" + "0200010001" + "ff0002" + "00" + "00800000" + "00" + "aabb"
+    tests := []struct {
+        name string
+        code string
+        ok   bool
+    }{
+        {"valid", valid, true},
+        {"legacy", "6001600055", false},
+        {"empty", "", false},
+        {"magic only", "ef00", false},
+        {"wrong version", "ef0002" + valid[6:], false},
+        {"no code sections", "ef0001" + "010004" + "020000" + "ff0002" + "00", false},
+        {"missing terminator", "ef0001" + "010004" + "0200010001" + "ff0002", false},
+        {"truncated header", valid[:16], false},
+        {"sections past end", "ef0001" + "010004" + "0200010010" + "ff0000" + "00" + "00800000", false},
+    }
+    for _, test := range tests {
+        code, err := hex.DecodeString(test.code)
+        if err != nil {
+            t.Fatal(err)
+        }
+        c := ParseEOF(code)
+        if (c != nil) != test.ok {
+            t.Errorf("%s: parsed is %v, expected %v", test.name, c != nil, test.ok)
+        }
+    }
+
+    code, _ := hex.DecodeString(valid)
+    c := ParseEOF(code)
+    if c.CodeOffset(0, 0) != 19 || c.DataSize() != 2 {
+        t.Errorf("code offset %d, data size %d", c.CodeOffset(0, 0), c.DataSize())
+    }
+    appended := EOFAppendData(code, []byte{0xcc})
+    if c := ParseEOF(appended); c == nil || c.DataSize() != 3 || appended[c.dataSizePos+1] != 3 {
+        t.Errorf("aux data is not appended")
+    }
+}
+
+type eofTestWriter struct {
+    out []byte
+}
+
+func (w *eofTestWriter) Write(data []byte) {
+    w.out = append(w.out, data...)
+}
+
+type eofTestStateDB struct {
+    codes map[[20]byte][]byte
+}
+
+func (s eofTestStateDB) GetNonce(addr [20]byte) uint64 {
+    return 0
+}
+
+func (s eofTestStateDB) GetCode(addr [20]byte) []byte {
+    return s.codes[addr]
+}
+
+func eofTestHex(t *testing.T, s string) []byte {
+    b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return b
+}
+
+func eofTestStack(vals ...uint64) []uint256.Int {
+    stack := []uint256.Int{}
+    for _, v := range vals {
+        stack = append(stack, *uint256.NewInt(v))
+    }
+    return stack
+}
+
+// handler is driven the same way as by geth hooks, opcodes get stack before execution
+func eofTestHandler(t *testing.T, logger string, addr [20]byte, code, input []byte) (*DepHandler, *eofTestWriter) {
+    w := new(eofTestWriter)
+    cfg := `{"kv":{"engine":"memory"},"past_unknown":true,"output_format":"json","logger":` + logger + `}`
+    h := NewDepHandler([]byte(cfg), w)
+    stateDB := eofTestStateDB{map[[20]byte][]byte{addr: code}}
+    h.StartTransactionRecording(false, addr, input, big.NewInt(1), 1000, [20]byte{0xee}, [32]byte{1}, code, true, true, stateDB)
+    h.HandleEnter(addr, input)
+    return h, w
+}
+
+func eofTestSection(h *DepHandler) uint16 {
+    return h.state.curState().stacked.Cur().section
+}
+
+func TestEOFRJumpV(t *testing.T) {
+    addr := [20]byte{0xc0}
+    // PUSH1 0, CALLDATALOAD, RJUMPV with 2 cases, STOP, STOP
+    code := eofTestHex(t, "ef0001 010004 020001000b ff0000 00" + "00800001" + "6000 35 e2 01 0000 0001 00 00")
+    input := eofTestHex(t, "0000000000000000000000000000000000000000000000000000000000000001")
+    h, w := eofTestHandler(t, `{"jump_targets":true,"omit_info":true}`, addr, code, input)
+
+    h.HandleOpcode(eofTestStack(), nil, addr, 0, byte(PUSH1), false, false)
+    h.HandleOpcode(eofTestStack(0), nil, addr, 2, byte(CALLDATALOAD), false, false)
+    h.HandleOpcode(eofTestStack(1), nil, addr, 3, byte(RJUMPV), false, false)
+    h.HandleOpcode(eofTestStack(), nil, addr, 10, byte(STOP), false, false)
+    h.HandleExit(nil, false)
+    h.EndTransactionRecording()
+
+    // case index is read from calldata, so it is a jump target
+    if !strings.Contains(string(w.out), "CALLDATA") {
+        t.Errorf("jump target of RJUMPV is not logged: %s", w.out)
+    }
+}
+
+func TestEOFCallFRetF(t *testing.T) {
+    addr := [20]byte{0xc0}
+    // section 0: CALLF 1, STOP; section 1: RETF
+    code := eofTestHex(t, "ef0001 010008 02000200040001 ff0000 00" + "00800000 00000000" + "e30001 00" + "e4")
+    h, _ := eofTestHandler(t, `{"omit_info":true}`, addr, code, nil)
+
+    h.HandleOpcode(eofTestStack(), nil, addr, 0, byte(CALLF), false, false)
+    if section := eofTestSection(h); section != 1 {
+        t.Errorf("section after CALLF is %d, expected 1", section)
+    }
+    h.HandleOpcode(eofTestStack(), nil, addr, 0, byte(RETF), false, false)
+    if section := eofTestSection(h); section != 0 {
+        t.Errorf("section after RETF is %d, expected 0", section)
+    }
+    h.HandleOpcode(eofTestStack(), nil, addr, 3, byte(STOP), false, false)
+    h.HandleExit(nil, false)
+    h.EndTransactionRecording()
+}
+
+func TestEOFCreateReturnContract(t *testing.T) {
+    addr := [20]byte{0xc0}
+    deploy := "ef0001 010004 0200010001 ff0000 00" + "00800000" + "00"
+    // PUSH1 0 (size), PUSH2 0x1000 (offset past memory), RETURNCONTRACT 0
+    initcode := "ef0001 010004 0200010007 030001 00000014 ff0000 00" + "00800002" + "6000 611000 ee00" + deploy
+    // PUSH1 0 (size), PUSH1 0 (offset), PUSH1 0 (salt), PUSH1 0 (value), EOFCREATE 0, STOP
+    code := eofTestHex(t, "ef0001 010004 020001000b 030001 00000035 ff0000 00" + "00800004" + "6000 6000 6000 6000 ec00 00" + initcode)
+    h, w := eofTestHandler(t, `{"codes":true,"omit_info":true}`, addr, code, nil)
+
+    for i := uint64(0); i < 4; i++ {
+        h.HandleOpcode(eofTestStack(make([]uint64, i)...), nil, addr, i * 2, byte(PUSH1), false, false)
+    }
+    h.HandleOpcode(eofTestStack(0, 0, 0, 0), nil, addr, 8, byte(EOFCREATE), false, false)
+
+    newAddr := EOFCreateAddress(addr, [32]byte{})
+    h.HandleEnter(newAddr, nil)
+    h.HandleOpcode(eofTestStack(), nil, newAddr, 0, byte(PUSH1), false, false)
+    h.HandleOpcode(eofTestStack(0), nil, newAddr, 2, byte(PUSH2), false, false)
+    h.HandleOpcode(eofTestStack(0, 0x1000), nil, newAddr, 5, byte(RETURNCONTRACT), false, false)
+    deployed := eofTestHex(t, deploy)
+    h.HandleExit(deployed, false)
+
+    h.HandleOpcode(eofTestStack(0), nil, addr, 10, byte(STOP), false, false)
+    h.HandleExit(nil, false)
+    h.EndTransactionRecording()
+
+    if !strings.Contains(string(w.out), hex.EncodeToString(deployed)) {
+        t.Errorf("deployed code is not logged: %s", w.out)
+    }
+}
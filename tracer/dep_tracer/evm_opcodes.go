@@ -184,6 +184,29 @@ const (
     LOG4
 )
 
+// 0xd0 range - eof data section.
+const (
+    DATALOAD  OpCode = 0xd0
+    DATALOADN OpCode = 0xd1
+    DATASIZE  OpCode = 0xd2
+    DATACOPY  OpCode = 0xd3
+)
+
+// 0xe0 range - eof control flow and stack.
+const (
+    RJUMP          OpCode = 0xe0
+    RJUMPI         OpCode = 0xe1
+    RJUMPV         OpCode = 0xe2
+    CALLF          OpCode = 0xe3
+    RETF           OpCode = 0xe4
+    JUMPF          OpCode = 0xe5
+    DUPN           OpCode = 0xe6
+    SWAPN          OpCode = 0xe7
+    EXCHANGE       OpCode = 0xe8
+    EOFCREATE      OpCode = 0xec
+    RETURNCONTRACT OpCode = 0xee
+)
+
 // 0xf0 range - closures.
 const (
     CREATE       OpCode = 0xf0
@@ -193,7 +216,11 @@ const (
     DELEGATECALL OpCode = 0xf4
     CREATE2      OpCode = 0xf5
 
+    RETURNDATALOAD  OpCode = 0xf7
+    EXTCALL         OpCode = 0xf8
+    EXTDELEGATECALL OpCode = 0xf9
     STATICCALL   OpCode = 0xfa
+    EXTSTATICCALL   OpCode = 0xfb
     REVERT       OpCode = 0xfd
     INVALID      OpCode = 0xfe
     SELFDESTRUCT OpCode = 0xff
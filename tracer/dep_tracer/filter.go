@@ -0,0 +1,86 @@
+package dep_tracer
+
+import (
+    "fmt"
+    "strings"
+    "math/big"
+    "encoding/hex"
+)
+
+type FilterDefinition struct {
+    IncludeTo   []string `json:"include_to"`
+    ExcludeTo   []string `json:"exclude_to"`
+    IncludeFrom []string `json:"include_from"`
+    ExcludeFrom []string `json:"exclude_from"`
+    BlockFrom   *uint64  `json:"block_from,omitempty"`
+    BlockTo     *uint64  `json:"block_to,omitempty"`
+    includeTo   map[Address]bool
+    excludeTo   map[Address]bool
+    includeFrom map[Address]bool
+    excludeFrom map[Address]bool
+}
+
+func ParseAddress(val string) Address {
+    val = strings.TrimPrefix(strings.ToLower(val), "0x")
+    data, err := hex.DecodeString(val)
+    if err != nil {
+        panic(fmt.Errorf("failed to parse address %s: %v", val, err))
+    }
+    if len(data) != 20 {
+        panic(fmt.Errorf("invalid address length %s", val))
+    }
+    var res Address
+    copy(res[:], data)
+    return res
+}
+
+func addressSet(vals []string) map[Address]bool {
+    res := map[Address]bool{}
+    for _, val := range vals {
+        res[ParseAddress(val)] = true
+    }
+    return res
+}
+
+func NewFilterDefinition(fd *FilterDefinition) *FilterDefinition {
+    if fd == nil {
+        fd = &FilterDefinition{}
+    }
+    fd.includeTo   = addressSet(fd.IncludeTo)
+    fd.excludeTo   = addressSet(fd.ExcludeTo)
+    fd.includeFrom = addressSet(fd.IncludeFrom)
+    fd.excludeFrom = addressSet(fd.ExcludeFrom)
+    if fd.BlockFrom != nil && fd.BlockTo != nil && *fd.BlockTo < *fd.BlockFrom {
+        panic("filter block_to is less than block_from")
+    }
+    return fd
+}
+
+// addr is the transaction target, or the created address for create transactions
+func (fd *FilterDefinition) Match(addr, origin Address, block *big.Int) bool {
+    if block != nil {
+        if !block.IsUint64() {
+            return false
+        }
+        number := block.Uint64()
+        if fd.BlockFrom != nil && number < *fd.BlockFrom {
+            return false
+        }
+        if fd.BlockTo != nil && number > *fd.BlockTo {
+            return false
+        }
+    }
+    if len(fd.includeTo) > 0 && !fd.includeTo[addr] {
+        return false
+    }
+    if fd.excludeTo[addr] {
+        return false
+    }
+    if len(fd.includeFrom) > 0 && !fd.includeFrom[origin] {
+        return false
+    }
+    if fd.excludeFrom[origin] {
+        return false
+    }
+    return true
+}
@@ -24,6 +24,8 @@ func CryptoProtectedDefinition() ProtectedDefinition {
     opcodes := []uint8{OPSLoad, OPSStore, OPTLoad, OPTStore, OPKeccak, OPCodeKeccak}
     opcodes = append(opcodes, OPEcRecover, OPSha256, OPRipemd160, OPModExp, OPEcAddX)
     opcodes = append(opcodes, OPEcAddY, OPEcMulX, OPEcMulY, OPEcPairing, OPBlake2F)
+    opcodes = append(opcodes, OPBlsG1Add, OPBlsG1Msm, OPBlsG2Add, OPBlsG2Msm, OPBlsPairing, OPBlsMapG1, OPBlsMapG2)
+    opcodes = append(opcodes, OPPrecompile)
     return NewProtectedDefinition("crypto", opcodes)
 }
 
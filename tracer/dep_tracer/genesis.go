@@ -0,0 +1,83 @@
+package dep_tracer
+
+import (
+    "os"
+    "fmt"
+    "strings"
+    "encoding/hex"
+    "encoding/json"
+    "github.com/holiman/uint256"
+)
+
+// marks that genesis is imported, so that restart does not overwrite later state
+var genesisImportedKey = []byte("genesis_imported")
+
+type GenesisAccount struct {
+    Code    string            `json:"code"`
+    Storage map[string]string `json:"storage"`
+}
+
+func parseGenesisHex(val string) []byte {
+    val = strings.TrimPrefix(strings.ToLower(val), "0x")
+    if len(val) % 2 == 1 {
+        val = "0" + val
+    }
+    data, err := hex.DecodeString(val)
+    if err != nil {
+        panic(fmt.Errorf("failed to parse genesis value %s: %v", val, err))
+    }
+    return data
+}
+
+// accepts both genesis json (with "alloc" field) and plain alloc
+func LoadGenesisAlloc(path string) map[Address]GenesisAccount {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        panic(err)
+    }
+    var file struct {
+        Alloc map[string]GenesisAccount `json:"alloc"`
+    }
+    if err := json.Unmarshal(data, &file); err != nil {
+        panic(fmt.Errorf("failed to parse genesis %s: %v", path, err))
+    }
+    alloc := file.Alloc
+    if alloc == nil {
+        if err := json.Unmarshal(data, &alloc); err != nil {
+            panic(fmt.Errorf("failed to parse genesis alloc %s: %v", path, err))
+        }
+    }
+    res := map[Address]GenesisAccount{}
+    for addr, account := range alloc {
+        res[ParseAddress(addr)] = account
+    }
+    return res
+}
+
+// code and slots of genesis accounts become GENESISCODE and GENESISSLOT constants
+func ImportGenesis(db *SimpleDB, path string) {
+    if db.versionsDB.Get(genesisImportedKey, true) != nil {
+        return
+    }
+    for addr, account := range LoadGenesisAlloc(path) {
+        db.SetKnownSince(addr, 0)
+        code := parseGenesisHex(account.Code)
+        if len(code) > 0 {
+            val := FormulaDEPBytes(db.ConstantNewWithShorts(OPGenesisCode, code))
+            db.CommitDEPBytesWithShorts(val)
+            db.SetCode(addr, val, CodeHash(code), Hash{})
+        }
+        for key, value := range account.Storage {
+            slot := new(uint256.Int).SetBytes(parseGenesisHex(key))
+            valueBin := new(uint256.Int).SetBytes(parseGenesisHex(value)).Bytes32()
+            if valueBin == (Hash{}) {
+                continue
+            }
+            val := FormulaDEPBytes(db.ConstantNewWithShorts(OPGenesisSlot, valueBin[:]))
+            db.CommitDEPBytesWithShorts(val)
+            db.SetSlot(addr, slot, val)
+        }
+    }
+    db.ResetFormulas()
+    db.versionsDB.Set(genesisImportedKey, []byte{1})
+}
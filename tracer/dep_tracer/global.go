@@ -1,37 +1,66 @@
 package dep_tracer
 
 import (
+    "math/big"
     "encoding/binary"
 )
 
-func SetupDB(kvEngine, kvRoot string, toLog *LoggerDefinition, pastUnknown bool, writer OutputWriter) *SimpleDB {
+func SetupDB(kvEngine, kvRoot string, toLog *LoggerDefinition, options SimpleDBOptions, writer OutputWriter) *SimpleDB {
     protected := []ProtectedDefinition{}
     protected = append(protected, CryptoProtectedDefinition())
 
     toLog = NewLoggerDefinition(toLog)
 
-    if kvEngine == "amnesia" {
-        pastUnknown = true
+    if kvEngine == "amnesia" || options.PastUnknownHybrid {
+        options.PastUnknown = true
     }
 
     return SimpleDBNew(
         protected, *toLog,
         kvEngine, kvRoot,
-        pastUnknown,
+        options,
         writer,
     )
 }
 
+// blocks which are not ancestors of the new one were reorged out, their writes are rolled back
+func BlockStart(db *SimpleDB, number *big.Int, hash, parentHash Hash) {
+    for _, block := range db.journal.Retracted(number, parentHash) {
+        db.journal.Rollback(block)
+        db.logger.LogRetracted(block.number, block.hash, block.txHashes)
+    }
+    db.journal.StartBlock(number, hash, parentHash)
+    db.blockWriter.Start()
+}
+
+// writes and output of failed block are discarded
+func BlockFinish(db *SimpleDB, failed bool) {
+    if failed {
+        db.journal.DiscardBlock()
+        db.blockWriter.Discard()
+        return
+    }
+    db.journal.EndBlock()
+    db.blockWriter.Flush()
+}
+
 func TransactionStart(db *SimpleDB, data DataStart) *TransactionDB {
     var state *TransactionDB
     if data.IsCreate {
         state = TransactionDBCreate(db, data.Address, Address{}, data.Input)
     } else {
-        state = TransactionDBCall(db, data.Address, data.Address, data.Input, data.Code)
+        codeAddress, code, delegated := resolveDelegation(data.Address, data.Code, data.DelegatedCode)
+        state = TransactionDBCall(db, data.Address, codeAddress, data.Input, code)
+        if delegated {
+            state.SetDelegated()
+        }
     }
 
-    db.logger.EnterContext(data.Block, data.Timestamp, data.Origin, data.TxHash)
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    db.logger.EnterContext(data.Block, data.Timestamp, data.Origin, data.TxHash, data.IsSystemCall)
+    if !data.IsSystemCall {
+        db.journal.AddTransaction(data.TxHash)
+    }
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
 
     return state
 }
@@ -44,6 +73,22 @@ func (data DataStart) Handle(db *SimpleDB, state *TransactionDB) {
     panic("DataStart shouldn't be called")
 }
 
+// code of authority is changed before execution, target of transaction is redirected
+func (data DataAuthorization) Handle(db *SimpleDB, state *TransactionDB) {
+    code := DelegationCode(data.Delegate)
+    val := []DEPByte{}
+    if len(code) > 0 {
+        val = FormulaDEPBytes(state.ConstantNewWithShorts(OPDelegation, code))
+    }
+    state.SetDelegation(data.Authority, val, code)
+
+    if data.Authority == state.Address() && !state.IsCreate() {
+        codeAddress, frameCode, delegated := resolveDelegation(data.Authority, code, data.DelegatedCode)
+        state.SetFrameCode(codeAddress, frameCode, delegated)
+        db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
+    }
+}
+
 func (data DataError) Handle(db *SimpleDB, state *TransactionDB) {
     if data.Reverted {
         state.Revert([]DEPByte{})
@@ -58,7 +103,7 @@ func (data DataPush) Handle(db *SimpleDB, state *TransactionDB) {
         return
     }
     code := state.Code()
-    val := OverflowSliceDEPBytes(code, data.Pc+1, data.Size)
+    val := OverflowSliceDEPBytes(code, state.CodeOffset(data.Pc)+1, data.Size)
     state.Stack().PushN(val)
 }
 
@@ -233,12 +278,21 @@ func (data DataCodeSize) Handle(db *SimpleDB, state *TransactionDB) {
     state.Stack().PushN(FormulaDEPBytes(val))
 }
 
+// EXTCODE* of legacy code see only magic of eof contracts
+func extCode(state *TransactionDB, addr Address, code []byte) []DEPByte {
+    res := state.GetCode(addr, code)
+    if IsEOF(code) && len(res) >= 2 {
+        return res[:2]
+    }
+    return res
+}
+
 func (data DataExtCodeSize) Handle(db *SimpleDB, state *TransactionDB) {
     addr := state.Stack().Pop()
     addrFormula := state.FormulaDepWithShorts(addr[32-20:])
 
     addrBin := data.Address
-    codeFormula := state.FormulaDepWithShorts(state.GetCode(addrBin, data.Code))
+    codeFormula := state.FormulaDepWithShorts(extCode(state, addrBin, data.Code))
 
     codeSizeBin := data.CodeSize.Bytes32()
 
@@ -251,12 +305,15 @@ func (data DataExtCodeHash) Handle(db *SimpleDB, state *TransactionDB) {
     addrFormula := state.FormulaDepWithShorts(addr[32-20:])
 
     addrBin := data.Address
-    codeFormula := state.FormulaDepWithShorts(state.GetCode(addrBin, data.Code))
+    codeFormula := state.FormulaDepWithShorts(extCode(state, addrBin, data.Code))
 
     hashBin := data.Hash
 
     val := state.FormulaNewWithShorts(OPCodeKeccak, hashBin[:], []Hash{codeFormula.hash, addrFormula.hash})
     state.Stack().PushN(FormulaDEPBytes(val))
+
+    // also tells whether account exists
+    state.AddWitness(addrBin, "code_hash", []byte{}, state.ConstantNewWithShorts(OPConstant, hashBin[:]))
 }
 
 func (data DataCalldataSize) Handle(db *SimpleDB, state *TransactionDB) {
@@ -294,7 +351,7 @@ func (data DataExtCodeCopy) Handle(db *SimpleDB, state *TransactionDB) {
     state.Stack().Pop() // codeOffset
     state.Stack().Pop() // length
 
-    val := OverflowSliceDEPBytes(state.GetCode(data.Address, data.Code), data.CodeOffset, data.Length)
+    val := OverflowSliceDEPBytes(extCode(state, data.Address, data.Code), data.CodeOffset, data.Length)
     state.Memory().SetN(data.MemoryOffset, val)
 }
 
@@ -372,6 +429,54 @@ func (data DataRevert) Handle(db *SimpleDB, state *TransactionDB) {
     state.Revert(val)
 }
 
+func jumpTarget(db *SimpleDB, state *TransactionDB, destination [32]DEPByte) {
+    if !db.logger.toLog.JumpTargets() || state.IsCodeConstant(destination[:]) {
+        return
+    }
+    state.AddJumpTarget(state.FormulaDepWithShorts(destination[:]))
+}
+
+func (data DataCallValue) Handle(db *SimpleDB, state *TransactionDB) {
+    if value := state.CallValue(); value != nil {
+        state.Stack().PushN(value)
+        return
+    }
+    DataConstant {
+        Op: OPCallValue,
+        Value: data.Value,
+    }.Handle(db, state)
+}
+
+func (data DataGas) Handle(db *SimpleDB, state *TransactionDB) {
+    if gas := state.CallGas(); db.callContext && gas != nil {
+        gasFormula := state.FormulaDepWithShorts(gas)
+        valBin := data.Value.Bytes32()
+        val := state.FormulaNewWithShorts(OPCallGas, valBin[:], []Hash{gasFormula.hash})
+        state.Stack().PushN(FormulaDEPBytes(val))
+        return
+    }
+    DataConstant {
+        Op: OPGas,
+        Value: data.Value,
+    }.Handle(db, state)
+}
+
+func (data DataJump) Handle(db *SimpleDB, state *TransactionDB) {
+    destination := state.Stack().Pop()
+    jumpTarget(db, state, destination)
+}
+
+func (data DataJumpI) Handle(db *SimpleDB, state *TransactionDB) {
+    destination := state.Stack().Pop()
+    condition := state.Stack().Pop()
+
+    jumpTarget(db, state, destination)
+
+    if db.implicitFlow {
+        state.AddGuard(data.Pc, state.FormulaDepWithShorts(condition[:]))
+    }
+}
+
 func (data DataEmpty) Handle(db *SimpleDB, state *TransactionDB) {
     for i := 0; i < data.N; i ++ {
         state.Stack().Pop()
@@ -387,6 +492,8 @@ func (data DataBalance) Handle(db *SimpleDB, state *TransactionDB) {
 
     val := state.FormulaNewWithShorts(OPBalance, balanceBin[:], []Hash{balance.hash, addrFormula.hash})
     state.Stack().PushN(FormulaDEPBytes(val))
+
+    state.AddWitness(data.Address, "balance", []byte{}, balance)
 }
 
 func (data DataSelfBalance) Handle(db *SimpleDB, state *TransactionDB) {
@@ -398,6 +505,8 @@ func (data DataSelfBalance) Handle(db *SimpleDB, state *TransactionDB) {
 
     val := state.FormulaNewWithShorts(OPBalance, balanceBin[:], []Hash{balance.hash, addr.hash})
     state.Stack().PushN(FormulaDEPBytes(val))
+
+    state.AddWitness(addrBin, "balance", []byte{}, balance)
 }
 
 func (data DataBlockHash) Handle(db *SimpleDB, state *TransactionDB) {
@@ -409,6 +518,9 @@ func (data DataBlockHash) Handle(db *SimpleDB, state *TransactionDB) {
 
     val := state.FormulaNewWithShorts(OPBlockHash, hashBin[:], []Hash{hash.hash, blockNumberFormula.hash})
     state.Stack().PushN(FormulaDEPBytes(val))
+
+    numberBin := data.Number.Bytes32()
+    state.AddWitness(Address{}, "block_hash", numberBin[:], hash)
 }
 
 func (data DataBlobHash) Handle(db *SimpleDB, state *TransactionDB) {
@@ -429,15 +541,15 @@ func (data DataCreateStart) Handle(db *SimpleDB, state *TransactionDB) {
 
     initcode := state.Memory().Load(data.Offset, data.Size)
 
-    state.Create(data.Address, Address{}, initcode, data.Data)
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    state.Create(data.Address, Address{}, []DEPByte{}, initcode, data.Data)
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
 }
 
 func (data DataCreateEnd) Handle(db *SimpleDB, state *TransactionDB) {
     addrBin := data.Address
     addr := state.ConstantNewWithShorts(OPCreateAddr, addrBin[:])
     state.Stack().PushN(FormulaDEPBytes(addr))
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
 }
 
 func (data DataCreate2Start) Handle(db *SimpleDB, state *TransactionDB) {
@@ -448,26 +560,62 @@ func (data DataCreate2Start) Handle(db *SimpleDB, state *TransactionDB) {
 
     initcode := state.Memory().Load(data.Offset, data.Size)
 
-    state.Create(data.Address, Address{}, initcode, data.Data)
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    state.Create(data.Address, Address{}, []DEPByte{}, initcode, data.Data)
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
 }
 
 func (data DataCreate2End) Handle(db *SimpleDB, state *TransactionDB) {
     addrBin := data.Address
     addr := state.ConstantNewWithShorts(OPCreate2Addr, addrBin[:])
     state.Stack().PushN(FormulaDEPBytes(addr))
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
 }
 
 func (data DataCallStart) Handle(db *SimpleDB, state *TransactionDB) {
-    for i := 0; i < data.N; i++ {
+    gas := state.Stack().Pop()
+    state.Stack().Pop() // address
+    n := data.N - 2
+    var value []DEPByte
+    if data.HasValue {
+        v := state.Stack().Pop()
+        value = v[:]
+        n -= 1
+    } else if data.Delegate {
+        // delegatecall keeps value of the caller
+        value = state.CallValue()
+    }
+    for i := 0; i < n; i++ {
         state.Stack().Pop()
     }
 
     calldata := state.Memory().Load(data.InOffset, data.InSize)
 
-    state.Call(data.Address, data.CodeAddress, calldata, data.Code)
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    transfer := data.HasValue && !data.Value.IsZero() && data.Address != state.Address()
+    codeAddress, code, delegated := resolveDelegation(data.CodeAddress, data.Code, data.DelegatedCode)
+    enterCall(db, state, data.Op, data.Address, codeAddress, code, delegated, calldata, value, gas[:], transfer)
+}
+
+// gas is nil if it is not passed explicitly
+func enterCall(db *SimpleDB, state *TransactionDB, op byte, address, codeAddress Address, code []byte, delegated bool, calldata, value, gas []DEPByte, transfer bool) {
+    var amount Formula
+    if transfer {
+        amount = state.FormulaDepWithShorts(value)
+    }
+
+    state.Call(address, codeAddress, calldata, code)
+    state.SetCallOp(op)
+    if delegated {
+        state.SetDelegated()
+    }
+    if transfer {
+        state.AddValueTransfer(amount)
+    }
+    if db.callContext {
+        state.SetCallContext(value, gas)
+    } else {
+        state.SetCallContext(value, nil)
+    }
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
 }
 
 func (data DataCallEnd) Handle(db *SimpleDB, state *TransactionDB) {
@@ -483,7 +631,156 @@ func (data DataCallEnd) Handle(db *SimpleDB, state *TransactionDB) {
     }
     val := state.ConstantNewWithShorts(OPCallResult, valBin)
     state.Stack().PushN(FormulaDEPBytes(val))
-    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash())
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
+}
+
+func (data DataRJump) Handle(db *SimpleDB, state *TransactionDB) {}
+
+func (data DataRJumpI) Handle(db *SimpleDB, state *TransactionDB) {
+    condition := state.Stack().Pop()
+
+    if db.implicitFlow {
+        state.AddGuard(data.Pc, state.FormulaDepWithShorts(condition[:]))
+    }
+}
+
+// case index chooses destination, so it is a jump target and a branch condition at once
+func (data DataRJumpV) Handle(db *SimpleDB, state *TransactionDB) {
+    index := state.Stack().Pop()
+
+    jumpTarget(db, state, index)
+
+    if db.implicitFlow {
+        state.AddGuard(data.Pc, state.FormulaDepWithShorts(index[:]))
+    }
+}
+
+func (data DataCallF) Handle(db *SimpleDB, state *TransactionDB) {
+    state.EnterCodeSection(data.Section)
+}
+
+func (data DataRetF) Handle(db *SimpleDB, state *TransactionDB) {
+    state.LeaveCodeSection()
+}
+
+func (data DataJumpF) Handle(db *SimpleDB, state *TransactionDB) {
+    state.JumpCodeSection(data.Section)
+}
+
+func (data DataExchange) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Exchange(data.N, data.M)
+}
+
+func (data DataDataLoad) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Pop() // offset
+    val := OverflowSliceDEPBytes(state.EOFData(), data.Offset, 32)
+    state.Stack().PushN(val)
+}
+
+func (data DataDataLoadN) Handle(db *SimpleDB, state *TransactionDB) {
+    val := OverflowSliceDEPBytes(state.EOFData(), data.Offset, 32)
+    state.Stack().PushN(val)
+}
+
+func (data DataDataSize) Handle(db *SimpleDB, state *TransactionDB) {
+    dataFormula := state.FormulaDepWithShorts(state.EOFData())
+
+    sizeBin := []byte{}
+    sizeBin = binary.BigEndian.AppendUint64(sizeBin, data.DataSize)
+
+    val := state.FormulaNewWithShorts(OPSize, sizeBin, []Hash{dataFormula.hash})
+    state.Stack().PushN(FormulaDEPBytes(val))
+}
+
+func (data DataDataCopy) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Pop() // memOffset
+    state.Stack().Pop() // offset
+    state.Stack().Pop() // size
+
+    d := OverflowSliceDEPBytes(state.EOFData(), data.DataOffset, data.Size)
+    state.Memory().SetN(data.MemoryOffset, d)
+}
+
+func (data DataReturndataLoad) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Pop() // offset
+    val := OverflowSliceDEPBytes(state.returndata, data.Offset, 32)
+    state.Stack().PushN(val)
+}
+
+func (data DataExtCallStart) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Pop() // address
+    state.Stack().Pop() // inOffset
+    state.Stack().Pop() // inSize
+    var value []DEPByte
+    if data.HasValue {
+        v := state.Stack().Pop()
+        value = v[:]
+    } else if data.Delegate {
+        value = state.CallValue()
+    }
+
+    calldata := state.Memory().Load(data.InOffset, data.InSize)
+
+    transfer := data.HasValue && !data.Value.IsZero() && data.Address != state.Address()
+    codeAddress, code, delegated := resolveDelegation(data.CodeAddress, data.Code, data.DelegatedCode)
+    // gas is not an operand of EXT*CALL
+    enterCall(db, state, data.Op, data.Address, codeAddress, code, delegated, calldata, value, nil, transfer)
+}
+
+func (data DataExtCallEnd) Handle(db *SimpleDB, state *TransactionDB) {
+    // 0 is success, 1 is revert, 2 is failure (failure is not distinguished from revert here)
+    var valBin []byte
+    if data.Success {
+        valBin = []byte{0}
+    } else {
+        valBin = []byte{1}
+    }
+    val := state.ConstantNewWithShorts(OPExtCallResult, valBin)
+    state.Stack().PushN(FormulaDEPBytes(val))
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
+}
+
+func (data DataEOFCreateStart) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Pop() // value
+    state.Stack().Pop() // salt
+    state.Stack().Pop() // inOffset
+    state.Stack().Pop() // inSize
+
+    initcode, initcodeBin := state.EOFSubcontainer(data.Index)
+    calldata := state.Memory().Load(data.InOffset, data.InSize)
+
+    state.Create(data.Address, Address{}, calldata, initcode, initcodeBin)
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
+}
+
+func (data DataEOFCreateEnd) Handle(db *SimpleDB, state *TransactionDB) {
+    addrBin := data.Address
+    addr := state.ConstantNewWithShorts(OPEOFCreateAddr, addrBin[:])
+    state.Stack().PushN(FormulaDEPBytes(addr))
+    db.logger.SetContractAddress(state.Address(), state.AddressVersion(), state.CodeAddress(), state.CodeHash(), state.InitcodeHash(), state.CallKind())
+}
+
+func (data DataReturnContract) Handle(db *SimpleDB, state *TransactionDB) {
+    state.Stack().Pop() // auxOffset
+    state.Stack().Pop() // auxSize
+
+    container, containerBin := state.EOFSubcontainer(data.Index)
+    c := ParseEOF(containerBin)
+    if c == nil {
+        panic("invalid eof deploy container")
+    }
+    aux := state.Memory().Load(data.Offset, data.Size)
+
+    // data size in header is updated, so it becomes a constant
+    sizeBin := data.Result[c.dataSizePos:c.dataSizePos+2]
+    size := FormulaDEPBytes(state.ConstantNewWithShorts(OPConstant, sizeBin))
+    code := []DEPByte{}
+    code = append(code, container[:c.dataSizePos]...)
+    code = append(code, size...)
+    code = append(code, container[c.dataSizePos+2:]...)
+    code = append(code, aux...)
+
+    state.Return(code, data.Result)
 }
 
 func (data DataPrecompileEcRecover) Handle(db *SimpleDB, state *TransactionDB) { // 01
@@ -654,3 +951,80 @@ func (data DataPointEvaluation) Handle(db *SimpleDB, state *TransactionDB) { //
 
     state.Return(FormulaDEPBytes(val), data.Result)
 }
+
+// EIP-2537 sizes: Fp element is padded to 64 bytes, G1 point is 2 Fp, G2 point is 2 Fp2
+const (
+    blsFpSize     = 64
+    blsG1Size     = 2 * blsFpSize
+    blsG2Size     = 4 * blsFpSize
+    blsScalarSize = 32
+)
+
+// slices input into operands by layout repeated until the end of input
+func precompileOperands(state *TransactionDB, d []DEPByte, layout []uint64) []Hash {
+    args := []Hash{}
+    for i := uint64(0); i < uint64(len(d)); {
+        for _, size := range layout {
+            operand := state.FormulaDepWithShorts(OverflowSliceDEPBytes(d, i, size))
+            args = append(args, operand.hash)
+            i += size
+        }
+    }
+    return args
+}
+
+func blsPrecompile(state *TransactionDB, op uint8, result []byte, layout []uint64) {
+    if len(result) < 1 {
+        state.Return([]DEPByte{}, []byte{})
+        return
+    }
+
+    args := precompileOperands(state, state.Calldata(), layout)
+    val := state.FormulaNewWithShorts(op, result, args)
+    state.Return(FormulaDEPBytes(val), result)
+}
+
+func (data DataPrecompileBlsG1Add) Handle(db *SimpleDB, state *TransactionDB) { // 0B
+    blsPrecompile(state, OPBlsG1Add, data.Result, []uint64{blsG1Size, blsG1Size})
+}
+
+func (data DataPrecompileBlsG1Msm) Handle(db *SimpleDB, state *TransactionDB) { // 0C
+    blsPrecompile(state, OPBlsG1Msm, data.Result, []uint64{blsG1Size, blsScalarSize})
+}
+
+func (data DataPrecompileBlsG2Add) Handle(db *SimpleDB, state *TransactionDB) { // 0D
+    blsPrecompile(state, OPBlsG2Add, data.Result, []uint64{blsG2Size, blsG2Size})
+}
+
+func (data DataPrecompileBlsG2Msm) Handle(db *SimpleDB, state *TransactionDB) { // 0E
+    blsPrecompile(state, OPBlsG2Msm, data.Result, []uint64{blsG2Size, blsScalarSize})
+}
+
+func (data DataPrecompileBlsPairing) Handle(db *SimpleDB, state *TransactionDB) { // 0F
+    blsPrecompile(state, OPBlsPairing, data.Result, []uint64{blsG1Size, blsG2Size})
+}
+
+func (data DataPrecompileBlsMapG1) Handle(db *SimpleDB, state *TransactionDB) { // 10
+    blsPrecompile(state, OPBlsMapG1, data.Result, []uint64{blsFpSize})
+}
+
+func (data DataPrecompileBlsMapG2) Handle(db *SimpleDB, state *TransactionDB) { // 11
+    blsPrecompile(state, OPBlsMapG2, data.Result, []uint64{2 * blsFpSize})
+}
+
+// opaque formula for precompiles which are not known (other chains, future forks),
+// without layout whole calldata is a single operand
+func (data DataPrecompileUnknown) Handle(db *SimpleDB, state *TransactionDB) {
+    d := state.Calldata()
+
+    addr := state.ConstantNewWithShorts(OPConstant, data.Address[:])
+    args := []Hash{addr.hash}
+    if len(data.Layout) == 0 {
+        args = append(args, state.FormulaDepWithShorts(d).hash)
+    } else {
+        args = append(args, precompileOperands(state, d, data.Layout)...)
+    }
+
+    val := state.FormulaNewWithShorts(OPPrecompile, data.Result, args)
+    state.Return(FormulaDEPBytes(val), data.Result)
+}
@@ -5,7 +5,6 @@ import (
     "strings"
     "reflect"
     "math/big"
-    "encoding/hex"
     "encoding/json"
     "github.com/holiman/uint256"
 )
@@ -14,14 +13,17 @@ type DepHandler struct {
     // flow variables
     returnHandled bool
     activated     bool
+    closed        bool
 
     // dep_tracer variables
     db            *SimpleDB
+    filter        *FilterDefinition
     state         *TransactionDB
     prevOPHandler OPHandler
     opHandlers    map[byte]OPHandler
     pcHandlers    map[Address]PrecompileHandler
     retHandlers   []OPHandler
+    recorder      *Recorder
 
     // input variables
     stateDB            StateDB
@@ -38,8 +40,15 @@ func NewDepHandler(cfg json.RawMessage, cw CallbackWriterCallback) *DepHandler {
             Root    string `json:"root"`
         } `json:"kv"`
         Logger      *LoggerDefinition `json:"logger,omitempty"`
+        Filter      *FilterDefinition `json:"filter,omitempty"`
         Output      string            `json:"output"`
         PastUnknown bool              `json:"past_unknown"`
+        PastUnknownHybrid bool        `json:"past_unknown_hybrid"`
+        ImplicitFlow bool             `json:"implicit_flow"`
+        CallContext bool              `json:"call_context"`
+        Precompiles map[string][]uint64 `json:"precompiles"`
+        Record      string            `json:"record"`
+        Genesis     string            `json:"genesis"`
     }
 
     var config depTracerConfig
@@ -56,6 +65,10 @@ func NewDepHandler(cfg json.RawMessage, cw CallbackWriterCallback) *DepHandler {
             panic("kv root (path) is not set")
         }
     }
+    // writes of skipped transactions are not stored, so their slots must stay unknown
+    if config.Filter != nil && !config.PastUnknown && !config.PastUnknownHybrid && config.KV.Engine != "amnesia" {
+        panic("filter requires past_unknown (or past_unknown_hybrid)")
+    }
     var writer OutputWriter
     if cw != nil {
         writer = NewCallbackWriter(cw)
@@ -71,20 +84,41 @@ func NewDepHandler(cfg json.RawMessage, cw CallbackWriterCallback) *DepHandler {
         config.KV.Engine,
         config.KV.Root,
         config.Logger,
-        config.PastUnknown,
+        SimpleDBOptions {
+            PastUnknown:       config.PastUnknown,
+            PastUnknownHybrid: config.PastUnknownHybrid,
+            ImplicitFlow:      config.ImplicitFlow,
+            CallContext:       config.CallContext,
+        },
         writer,
     )
 
+    if config.Genesis != "" {
+        ImportGenesis(db, config.Genesis)
+    }
+
+    var recorder *Recorder
+    if config.Record != "" {
+        recorder = NewRecorder(config.Record)
+    }
+
+    pcHandlers := NewPrecompileHandlers()
+    for addr, layout := range config.Precompiles {
+        NewExtraPrecompileHandler(ParseAddress(addr), layout).Register(pcHandlers)
+    }
+
     return &DepHandler{
         returnHandled: false,
         activated:     false,
 
         db:            db,
+        filter:        NewFilterDefinition(config.Filter),
         state:         nil,
         prevOPHandler: nil,
         opHandlers:    NewOPHandlers(),
-        pcHandlers:    NewPrecompileHandlers(),
+        pcHandlers:    pcHandlers,
         retHandlers:   []OPHandler{},
+        recorder:      recorder,
 
         stateDB:       nil,
         returnAddress: Address{},
@@ -97,17 +131,42 @@ type StateDB interface {
     GetCode(addr [20]byte) []byte
 }
 
+func (handler *DepHandler) ShouldRecordTransaction(addr [20]byte, origin [20]byte, block *big.Int) bool {
+    return handler.filter.Match(addr, origin, block)
+}
+
+// blocks are optional, without them nothing is journaled and reorgs are not detected
+func (handler *DepHandler) StartBlock(number *big.Int, hash, parentHash [32]byte) {
+    if handler.activated {
+        panic("StartBlock called during transaction")
+    }
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordBlockStart, Block: number, BlockHash: hash[:], ParentHash: parentHash[:]})
+        defer handler.recorder.FlushOnPanic()
+    }
+
+    BlockStart(handler.db, number, hash, parentHash)
+}
+
+// writes of the block are committed only if it did not fail
+func (handler *DepHandler) EndBlock(failed bool) {
+    if handler.activated {
+        panic("EndBlock called during transaction")
+    }
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordBlockEnd, HasError: failed})
+        defer handler.recorder.Flush()
+    }
+
+    BlockFinish(handler.db, failed)
+}
+
 func (handler *DepHandler) StartTransactionRecording(
     isCreate bool, addr [20]byte, input []byte, block *big.Int,
     timestamp uint64, origin [20]byte, txHash [32]byte,
     code []byte, isSelfdestruct6780, isRandom bool, stateDB StateDB,
 ) {
-    if handler.activated {
-        panic("StartTransactionRecording activated twice")
-    }
-    handler.activated = true;
-
-    startData := DataStart {
+    handler.startRecording(DataStart {
         IsCreate: isCreate,
         Address: addr,
         Input: input,
@@ -116,6 +175,40 @@ func (handler *DepHandler) StartTransactionRecording(
         Origin: origin,
         TxHash: txHash,
         Code: code,
+    }, isSelfdestruct6780, isRandom, stateDB)
+}
+
+// system call is executed outside of transactions by block processing, it is recorded
+// as transaction of SystemAddress without hash and finished by EndTransactionRecording
+func (handler *DepHandler) StartSystemCallRecording(
+    addr [20]byte, input []byte, block *big.Int, timestamp uint64,
+    code []byte, isSelfdestruct6780, isRandom bool, stateDB StateDB,
+) {
+    handler.startRecording(DataStart {
+        Address: addr,
+        Input: input,
+        Block: block,
+        Timestamp: timestamp,
+        Origin: SystemAddress,
+        Code: code,
+        IsSystemCall: true,
+    }, isSelfdestruct6780, isRandom, stateDB)
+}
+
+func (handler *DepHandler) startRecording(startData DataStart, isSelfdestruct6780, isRandom bool, stateDB StateDB) {
+    if handler.activated {
+        panic("StartTransactionRecording activated twice")
+    }
+    handler.activated = true;
+
+    if handler.recorder != nil {
+        handler.recorder.RecordStart(startData, isSelfdestruct6780, isRandom)
+        defer handler.recorder.FlushOnPanic()
+        stateDB = handler.recorder.StateDB(stateDB)
+    }
+
+    if !startData.IsCreate {
+        startData.DelegatedCode = delegatedCode(stateDB, startData.Code)
     }
     handler.state = TransactionStart(handler.db, startData)
     handler.isSelfdestruct6780 = isSelfdestruct6780
@@ -123,16 +216,75 @@ func (handler *DepHandler) StartTransactionRecording(
     handler.stateDB = stateDB
 }
 
+// EIP-7702 authorization applied before execution, zero delegate clears delegation
+func (handler *DepHandler) HandleAuthorization(authority, delegate [20]byte) {
+    if !handler.activated {
+        panic("HandleAuthorization is not activated")
+    }
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordAuthorization, Address: authority[:], Delegate: delegate[:]})
+        defer handler.recorder.FlushOnPanic()
+    }
+
+    var code []byte
+    if delegate != (Address{}) {
+        code = handler.stateDB.GetCode(delegate)
+    }
+    DataAuthorization {
+        Authority: authority,
+        Delegate: delegate,
+        DelegatedCode: code,
+    }.Handle(handler.db, handler.state)
+}
+
 func (handler *DepHandler) EndTransactionRecording() {
     if !handler.activated {
         panic("EndTransactionRecording is not activated")
     }
     handler.activated = false;
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordEnd})
+        defer handler.recorder.Flush()
+    }
 
     TransactionFinish(handler.state)
     handler.state = nil
 }
 
+// drops the current transaction after an error, so that handler can trace the next one,
+// writes of the transaction are not committed
+func (handler *DepHandler) AbortTransaction() {
+    if !handler.activated {
+        return
+    }
+    handler.activated = false
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordAbort})
+        handler.recorder.Flush()
+    }
+
+    handler.db.ResetFormulas()
+    handler.state = nil
+    handler.prevOPHandler = nil
+    handler.retHandlers = []OPHandler{}
+    handler.returnHandled = false
+    handler.stateDB = nil
+}
+
+// releases kv, output and recorder files, so that the same paths can be opened again,
+// unfinished transaction is aborted
+func (handler *DepHandler) Close() {
+    if handler.closed {
+        return
+    }
+    handler.closed = true
+    handler.AbortTransaction()
+    if handler.recorder != nil {
+        handler.recorder.Close()
+    }
+    handler.db.Close()
+}
+
 func (handler *DepHandler) HandleOpcode(
     stack []uint256.Int, memory []byte, addr [20]byte,
     pc uint64, op byte, isInvalid bool, hasError bool,
@@ -140,6 +292,10 @@ func (handler *DepHandler) HandleOpcode(
     if !handler.activated {
         panic("HandleOpcode is not activated")
     }
+    if handler.recorder != nil {
+        handler.recorder.RecordOpcode(stack, memory, addr, pc, op, isInvalid, hasError)
+        defer handler.recorder.FlushOnPanic()
+    }
 
     stackSize := len(stack)
 
@@ -197,6 +353,10 @@ func (handler *DepHandler) HandleEnter(to [20]byte, input []byte) {
     if !handler.activated {
         panic("HandleEnter is not activated")
     }
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordEnter, Address: to[:], Input: input})
+        defer handler.recorder.FlushOnPanic()
+    }
 
     handler.returnAddress = to
     handler.returnInput = input
@@ -206,6 +366,10 @@ func (handler *DepHandler) HandleFault(op byte) {
     if !handler.activated {
         panic("HandleFault is not activated")
     }
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordFault, Op: op})
+        defer handler.recorder.FlushOnPanic()
+    }
 
     o := OpCode(op)
     if o == REVERT {
@@ -222,13 +386,20 @@ func (handler *DepHandler) HandleExit(output []byte, hasError bool) {
     if !handler.activated {
         panic("HandleExit is not activated")
     }
+    if handler.recorder != nil {
+        handler.recorder.Record(RecordedEvent{Kind: RecordExit, Output: output, HasError: hasError})
+        defer handler.recorder.FlushOnPanic()
+    }
 
     if !handler.returnHandled {
         if len(output) > 0 {
             if ph, ok := handler.pcHandlers[handler.returnAddress]; ok {
                 ph.Execute(handler.db, handler.state, handler.returnInput, output)
             } else {
-                panic(fmt.Sprintf("Unknown precompile %", hex.EncodeToString(handler.returnAddress[:])))
+                DataPrecompileUnknown {
+                    Address: handler.returnAddress,
+                    Result: output,
+                }.Handle(handler.db, handler.state)
             }
         } else {
             DataError {
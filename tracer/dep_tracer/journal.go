@@ -0,0 +1,331 @@
+package dep_tracer
+
+import (
+    "math/big"
+    "encoding/json"
+    "encoding/binary"
+)
+
+// blocks deeper than this are considered final, their journals are dropped
+const maxJournaledBlocks = 128
+
+type journalEntry struct {
+    db      int // index of BlockDB in journal
+    key     []byte
+    value   []byte
+    existed bool
+}
+
+// previous values of everything written by block, so that it can be rolled back on reorg
+type BlockJournal struct {
+    number     *big.Int
+    hash       Hash
+    parentHash Hash
+    txHashes   []Hash
+    entries    []journalEntry
+}
+
+// journals are also kept in kv (sequence numbers first..next), so reorg crossing restart
+// is rolled back too, kv instances are not written atomically, so crash in the middle of
+// block commit still leaves it partially applied
+type Journal struct {
+    blocks  []*BlockJournal // oldest first
+    current *BlockJournal
+    dbs     []*BlockDB
+    db      DB
+    first   uint64
+    next    uint64
+}
+
+type storedJournalEntry struct {
+    DB      int    `json:"db"`
+    Key     []byte `json:"key"`
+    Value   []byte `json:"value"`
+    Existed bool   `json:"existed"`
+}
+
+type storedBlockJournal struct {
+    Number     string               `json:"number"`
+    Hash       []byte               `json:"hash"`
+    ParentHash []byte               `json:"parent_hash"`
+    TxHashes   [][]byte             `json:"txhashes"`
+    Entries    []storedJournalEntry `json:"entries"`
+}
+
+var journalRangeKey = []byte("range")
+
+func journalBlockKey(seq uint64) []byte {
+    return binary.BigEndian.AppendUint64([]byte("block"), seq)
+}
+
+// BlockDBs are registered in the same order on every start, so their indexes are stable
+func NewJournal(db DB) *Journal {
+    j := &Journal{
+        blocks:  []*BlockJournal{},
+        current: nil,
+        dbs:     []*BlockDB{},
+        db:      db,
+        first:   0,
+        next:    0,
+    }
+    if r := db.Get(journalRangeKey, true); r != nil {
+        j.first = binary.BigEndian.Uint64(r[:8])
+        j.next = binary.BigEndian.Uint64(r[8:])
+        for seq := j.first; seq < j.next; seq++ {
+            j.blocks = append(j.blocks, decodeBlockJournal(db.Get(journalBlockKey(seq), false)))
+        }
+    }
+    return j
+}
+
+func (j *Journal) saveRange() {
+    r := binary.BigEndian.AppendUint64([]byte{}, j.first)
+    r = binary.BigEndian.AppendUint64(r, j.next)
+    j.db.Set(journalRangeKey, r)
+}
+
+func (j *Journal) Close() {
+    j.db.Close()
+}
+
+func (b *BlockJournal) encode() []byte {
+    stored := storedBlockJournal {
+        Number:     b.number.String(),
+        Hash:       b.hash[:],
+        ParentHash: b.parentHash[:],
+        TxHashes:   [][]byte{},
+        Entries:    []storedJournalEntry{},
+    }
+    for _, txHash := range b.txHashes {
+        stored.TxHashes = append(stored.TxHashes, txHash[:])
+    }
+    for _, entry := range b.entries {
+        stored.Entries = append(stored.Entries, storedJournalEntry{entry.db, entry.key, entry.value, entry.existed})
+    }
+    res, err := json.Marshal(stored)
+    if err != nil {
+        panic(err)
+    }
+    return res
+}
+
+func decodeBlockJournal(data []byte) *BlockJournal {
+    var stored storedBlockJournal
+    if err := json.Unmarshal(data, &stored); err != nil {
+        panic(err)
+    }
+    number, ok := new(big.Int).SetString(stored.Number, 10)
+    if !ok {
+        panic("invalid block number in journal")
+    }
+    b := &BlockJournal{
+        number:   number,
+        txHashes: []Hash{},
+        entries:  []journalEntry{},
+    }
+    copy(b.hash[:], stored.Hash)
+    copy(b.parentHash[:], stored.ParentHash)
+    for _, txHash := range stored.TxHashes {
+        var h Hash
+        copy(h[:], txHash)
+        b.txHashes = append(b.txHashes, h)
+    }
+    for _, entry := range stored.Entries {
+        b.entries = append(b.entries, journalEntry{entry.DB, entry.Key, entry.Value, entry.Existed})
+    }
+    return b
+}
+
+func (j *Journal) StartBlock(number *big.Int, hash, parentHash Hash) {
+    if j.current != nil {
+        panic("StartBlock called before EndBlock")
+    }
+    j.current = &BlockJournal{
+        number:     number,
+        hash:       hash,
+        parentHash: parentHash,
+        txHashes:   []Hash{},
+        entries:    []journalEntry{},
+    }
+}
+
+// writes of the block are flushed only when it is final
+func (j *Journal) EndBlock() {
+    if j.current == nil {
+        panic("EndBlock called before StartBlock")
+    }
+    for _, db := range j.dbs {
+        db.commit(j.current)
+    }
+    j.blocks = append(j.blocks, j.current)
+    j.db.Set(journalBlockKey(j.next), j.current.encode())
+    j.next++
+    j.current = nil
+    for len(j.blocks) > maxJournaledBlocks {
+        j.blocks = j.blocks[1:]
+        j.db.Delete(journalBlockKey(j.first))
+        j.first++
+    }
+    j.saveRange()
+}
+
+func (j *Journal) DiscardBlock() {
+    if j.current == nil {
+        panic("DiscardBlock called before StartBlock")
+    }
+    for _, db := range j.dbs {
+        db.discard()
+    }
+    j.current = nil
+}
+
+func (j *Journal) AddTransaction(txHash Hash) {
+    if j.current != nil {
+        j.current.txHashes = append(j.current.txHashes, txHash)
+    }
+}
+
+// blocks which are not ancestors of the new block, newest first, they have to be rolled back
+// in this order, if parent is not journaled (too deep reorg) only blocks with the same or
+// bigger number are known to be retracted
+func (j *Journal) Retracted(number *big.Int, parentHash Hash) []*BlockJournal {
+    parentFound := false
+    for _, block := range j.blocks {
+        if block.hash == parentHash {
+            parentFound = true
+        }
+    }
+    res := []*BlockJournal{}
+    for len(j.blocks) > 0 {
+        block := j.blocks[len(j.blocks)-1]
+        if block.hash == parentHash {
+            break
+        }
+        if !parentFound && block.number.Cmp(number) < 0 {
+            break
+        }
+        res = append(res, block)
+        j.blocks = j.blocks[:len(j.blocks)-1]
+    }
+    return res
+}
+
+// journal of the block is dropped from kv only after its writes are restored
+func (j *Journal) Rollback(b *BlockJournal) {
+    for i := len(b.entries) - 1; i >= 0; i-- {
+        entry := b.entries[i]
+        db := j.dbs[entry.db].db
+        if entry.existed {
+            db.Set(entry.key, entry.value)
+        } else {
+            db.Delete(entry.key)
+        }
+    }
+    if j.next > j.first {
+        j.next--
+        j.db.Delete(journalBlockKey(j.next))
+        j.saveRange()
+    }
+}
+
+type pendingValue struct {
+    value   []byte
+    deleted bool
+}
+
+// overlay of DB for the current block, without block writes go directly to DB
+type BlockDB struct {
+    db      DB
+    index   int
+    journal *Journal
+    pending map[string]pendingValue
+}
+
+func NewBlockDB(db DB, journal *Journal) *BlockDB {
+    res := &BlockDB{
+        db:      db,
+        index:   len(journal.dbs),
+        journal: journal,
+        pending: map[string]pendingValue{},
+    }
+    journal.dbs = append(journal.dbs, res)
+    return res
+}
+
+func (db *BlockDB) Get(key []byte, optional bool) []byte {
+    if val, ok := db.pending[string(key)]; ok {
+        if !val.deleted {
+            return val.value
+        }
+        if optional {
+            return nil
+        }
+        panic("key not found")
+    }
+    return db.db.Get(key, optional)
+}
+
+func (db *BlockDB) Has(key []byte) bool {
+    if val, ok := db.pending[string(key)]; ok {
+        return !val.deleted
+    }
+    return db.db.Has(key)
+}
+
+func (db *BlockDB) Set(key, value []byte) {
+    if db.journal.current == nil {
+        db.db.Set(key, value)
+        return
+    }
+    db.pending[string(key)] = pendingValue{value, false}
+}
+
+func (db *BlockDB) Delete(key []byte) {
+    if db.journal.current == nil {
+        db.db.Delete(key)
+        return
+    }
+    db.pending[string(key)] = pendingValue{nil, true}
+}
+
+func (db *BlockDB) DumpAllDebug() map[string][]byte {
+    res := map[string][]byte{}
+    for key, value := range db.db.DumpAllDebug() {
+        res[key] = value
+    }
+    for key, val := range db.pending {
+        if val.deleted {
+            delete(res, key)
+        } else {
+            res[key] = val.value
+        }
+    }
+    return res
+}
+
+func (db *BlockDB) Close() {
+    db.db.Close()
+}
+
+// previous values are saved into journal of the block
+func (db *BlockDB) commit(block *BlockJournal) {
+    for key, val := range db.pending {
+        k := []byte(key)
+        existed := db.db.Has(k)
+        var prev []byte
+        if existed {
+            prev = db.db.Get(k, false)
+        }
+        block.entries = append(block.entries, journalEntry{db.index, k, prev, existed})
+        if val.deleted {
+            db.db.Delete(k)
+        } else {
+            db.db.Set(k, val.value)
+        }
+    }
+    db.discard()
+}
+
+func (db *BlockDB) discard() {
+    db.pending = map[string]pendingValue{}
+}
@@ -34,6 +34,14 @@ type DataStart struct {
     Origin    Address `json:"origin"`
     TxHash    Hash    `json:"tx_hash"`
     Code      []byte         `json:"code"`
+    DelegatedCode []byte     `json:"delegated_code"`
+    IsSystemCall  bool       `json:"is_system_call"`
+}
+
+type DataAuthorization struct {
+    Authority     Address `json:"authority"`
+    Delegate      Address `json:"delegate"`
+    DelegatedCode []byte  `json:"delegated_code"`
 }
 
 type DataError struct {
@@ -185,7 +193,7 @@ type DataCalldataLoad struct {
 type DataLog struct {
     Offset    uint64 `json:"offset"`
     Size      uint64 `json:"size"`
-    TopicsNum int    `json:"size"`
+    TopicsNum int    `json:"topics_num"`
 }
 
 type DataReturn struct {
@@ -205,11 +213,26 @@ type DataRevert struct {
     Size   uint64 `json:"size"`
 }
 
+type DataCallValue struct {
+    Value uint256.Int `json:"value"`
+}
+
+type DataGas struct {
+    Value uint256.Int `json:"value"`
+}
+
+type DataJump struct {}
+
+type DataJumpI struct {
+    Pc uint64 `json:"pc"`
+}
+
 type DataEmpty struct {
     N int `json:"n"`
 }
 
 type DataBalance struct {
+    Address Address     `json:"address"`
     Balance uint256.Int `json:"balance"`
 }
 
@@ -218,7 +241,8 @@ type DataSelfBalance struct {
 }
 
 type DataBlockHash struct {
-    Hash Hash `json:"hash"`
+    Number uint256.Int `json:"number"`
+    Hash   Hash        `json:"hash"`
 }
 
 type DataBlobHash struct {
@@ -248,12 +272,17 @@ type DataCreate2End struct {
 }
 
 type DataCallStart struct {
+    Op          byte           `json:"op"`
     N           int            `json:"n"`
+    HasValue    bool           `json:"has_value"`
+    Value       uint256.Int    `json:"value"`
+    Delegate    bool           `json:"delegate"`
     Address     Address `json:"address"`
     CodeAddress Address `json:"code_address"`
     InOffset    uint64         `json:"in_offset"`
     InSize      uint64         `json:"in_size"`
     Code        []byte         `json:"code"`
+    DelegatedCode []byte       `json:"delegated_code"`
 }
 
 type DataCallEnd struct {
@@ -304,3 +333,119 @@ type DataPrecompileBlake2F struct {
 type DataPointEvaluation struct {
     Result []byte `json:"result"`
 }
+
+type DataPrecompileBlsG1Add struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileBlsG1Msm struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileBlsG2Add struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileBlsG2Msm struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileBlsPairing struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileBlsMapG1 struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileBlsMapG2 struct {
+    Result []byte `json:"result"`
+}
+
+type DataPrecompileUnknown struct {
+    Address Address  `json:"address"`
+    Layout  []uint64 `json:"layout"`
+    Result  []byte   `json:"result"`
+}
+
+type DataRJump struct {}
+
+type DataRJumpI struct {
+    Pc uint64 `json:"pc"`
+}
+
+type DataRJumpV struct {
+    Pc uint64 `json:"pc"`
+}
+
+type DataCallF struct {
+    Section uint16 `json:"section"`
+}
+
+type DataRetF struct {}
+
+type DataJumpF struct {
+    Section uint16 `json:"section"`
+}
+
+type DataExchange struct {
+    N int `json:"n"`
+    M int `json:"m"`
+}
+
+type DataDataLoad struct {
+    Offset uint64 `json:"offset"`
+}
+
+type DataDataLoadN struct {
+    Offset uint64 `json:"offset"`
+}
+
+type DataDataSize struct {
+    DataSize uint64 `json:"data_size"`
+}
+
+type DataDataCopy struct {
+    MemoryOffset uint64 `json:"memory_offset"`
+    DataOffset   uint64 `json:"data_offset"`
+    Size         uint64 `json:"size"`
+}
+
+type DataReturndataLoad struct {
+    Offset uint64 `json:"offset"`
+}
+
+type DataExtCallStart struct {
+    Op          byte        `json:"op"`
+    HasValue    bool        `json:"has_value"`
+    Value       uint256.Int `json:"value"`
+    Delegate    bool        `json:"delegate"`
+    Address     Address     `json:"address"`
+    CodeAddress Address     `json:"code_address"`
+    InOffset    uint64      `json:"in_offset"`
+    InSize      uint64      `json:"in_size"`
+    Code        []byte      `json:"code"`
+    DelegatedCode []byte    `json:"delegated_code"`
+}
+
+type DataExtCallEnd struct {
+    Success bool `json:"success"`
+}
+
+type DataEOFCreateStart struct {
+    Address  Address `json:"address"`
+    Index    uint8   `json:"index"`
+    InOffset uint64  `json:"in_offset"`
+    InSize   uint64  `json:"in_size"`
+}
+
+type DataEOFCreateEnd struct {
+    Address Address `json:"address"`
+}
+
+type DataReturnContract struct {
+    Index  uint8  `json:"index"`
+    Offset uint64 `json:"offset"`
+    Size   uint64 `json:"size"`
+    Result []byte `json:"result"`
+}
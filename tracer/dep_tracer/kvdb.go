@@ -8,9 +8,12 @@ import (
 
 type DB interface {
     Get(key []byte, optional bool) []byte
+    // stored empty value may be returned by Get as nil, so existence is checked separately
+    Has(key []byte) bool
     Set(key, value []byte)
     Delete(key []byte)
     DumpAllDebug() map[string][]byte
+    Close()
 }
 
 func NewDB(engine, root, name string) DB {
@@ -63,6 +66,14 @@ func (db LevelDB) Get(key []byte, optional bool) []byte {
     }
 }
 
+func (db LevelDB) Has(key []byte) bool {
+    res, err := db.db.Has(key, nil)
+    if err != nil {
+        panic(err)
+    }
+    return res
+}
+
 func (db LevelDB) Set(key, value []byte) {
     err := db.db.Put(key, value, nil)
     if err != nil {
@@ -93,6 +104,12 @@ func (db LevelDB) DumpAllDebug() map[string][]byte {
     return res
 }
 
+func (db LevelDB) Close() {
+    if err := db.db.Close(); err != nil {
+        panic(err)
+    }
+}
+
 
 var riakDB *riak.Client = nil
 type RiakDB struct {
@@ -115,7 +132,7 @@ func NewRiakDB(root, name string) RiakDB {
     return db
 }
 
-func (db RiakDB) Get(key []byte, optional bool) []byte {
+func (db RiakDB) fetch(key []byte) []*riak.Object {
     cmd, err := riak.NewFetchValueCommandBuilder().
         WithBucket(db.name).
         WithKey(string(key)).
@@ -130,7 +147,11 @@ func (db RiakDB) Get(key []byte, optional bool) []byte {
     }
 
     fcmd := cmd.(*riak.FetchValueCommand)
-    values := fcmd.Response.Values
+    return fcmd.Response.Values
+}
+
+func (db RiakDB) Get(key []byte, optional bool) []byte {
+    values := db.fetch(key)
     if len(values) < 1 {
         if optional {
             return nil
@@ -142,6 +163,10 @@ func (db RiakDB) Get(key []byte, optional bool) []byte {
     }
 }
 
+func (db RiakDB) Has(key []byte) bool {
+    return len(db.fetch(key)) > 0
+}
+
 func (db RiakDB) Set(key, value []byte) {
     content := &riak.Object{
         Bucket:      db.name,
@@ -182,6 +207,9 @@ func (db RiakDB) DumpAllDebug() map[string][]byte {
     panic("DumpAllDebug() not implemented")
 }
 
+// client is shared by all buckets, so it is kept open
+func (db RiakDB) Close() {}
+
 
 type MemoryDB struct {
     data map[string][]byte
@@ -203,6 +231,11 @@ func (db MemoryDB) Get(key []byte, optional bool) []byte {
     panic("key not found")
 }
 
+func (db MemoryDB) Has(key []byte) bool {
+    _, ok := db.data[string(key)]
+    return ok
+}
+
 func (db MemoryDB) Set(key, value []byte) {
     db.data[string(key)] = value
 }
@@ -215,6 +248,8 @@ func (db MemoryDB) DumpAllDebug() map[string][]byte {
     return db.data
 }
 
+func (db MemoryDB) Close() {}
+
 
 type AmnesiaDB struct {}
 
@@ -229,10 +264,16 @@ func (db AmnesiaDB) Get(key []byte, optional bool) []byte {
     panic("key not found")
 }
 
+func (db AmnesiaDB) Has(key []byte) bool {
+    return false
+}
+
 func (db AmnesiaDB) Set(key, value []byte) {}
 
 func (db AmnesiaDB) Delete(key []byte) {}
 
 func (db AmnesiaDB) DumpAllDebug() map[string][]byte {
     return map[string][]byte{}
-}
\ No newline at end of file
+}
+
+func (db AmnesiaDB) Close() {}
\ No newline at end of file
@@ -17,13 +17,28 @@ type LoggerDefinition struct {
 
     FinalSlotsShort bool     `json:"final_slots_short"`
     FinalSlotsFull  bool     `json:"final_slots"`
+    FinalTransientShort bool `json:"final_transient_short"`
+    FinalTransientFull bool  `json:"final_transient"`
     CodesShort      bool     `json:"codes_short"`
     CodesFull       bool     `json:"codes"`
     ReturnDataShort bool     `json:"return_data_short"`
     ReturnDataFull  bool     `json:"return_data"`
     LogsShort       bool     `json:"logs_short"`
     LogsFull        bool     `json:"logs"`
+    JumpTargetsShort bool    `json:"jump_targets_short"`
+    JumpTargetsFull bool     `json:"jump_targets"`
+    ValueTransfersShort bool `json:"value_transfers_short"`
+    ValueTransfersFull bool  `json:"value_transfers"`
+    Witness         bool     `json:"witness"`
+    Retracted       bool     `json:"retracted"`
     SolView         bool     `json:"sol_view"`
+    Abi             []string `json:"abi"`
+    abi             *AbiRegistry
+    StorageLayouts  map[string]string `json:"storage_layouts"`
+    storageLayouts  map[Address]*StorageLayout
+    SolViewCompilers map[string]string `json:"sol_view_compilers"`
+    solViewCompilers map[Address]string
+    SolViewFallback string   `json:"sol_view_fallback"`
 
     MinimalInfo     bool     `json:"minimal_info"`
     OmitInfo        bool     `json:"omit_info"`
@@ -44,6 +59,7 @@ func NewLoggerDefinition(ld *LoggerDefinition) *LoggerDefinition {
         ld.LogsShort       = false
         ld.LogsFull        = true
         ld.SolView         = true
+        ld.Retracted       = true
     }
     ld.opcodesShort      = map[uint64]bool{}
     ld.opcodesFull       = map[uint64]bool{}
@@ -61,6 +77,24 @@ func NewLoggerDefinition(ld *LoggerDefinition) *LoggerDefinition {
         }
         ld.opcodesFull[val] = true
     }
+    ld.abi = NewAbiRegistry(ld.Abi)
+    ld.storageLayouts    = map[Address]*StorageLayout{}
+    for addr, path := range ld.StorageLayouts {
+        ld.storageLayouts[ParseAddress(addr)] = LoadStorageLayout(path)
+    }
+    ld.solViewCompilers  = map[Address]string{}
+    for addr, compiler := range ld.SolViewCompilers {
+        if compiler != SolViewSolidity && compiler != SolViewVyper && compiler != SolViewAuto {
+            panic("Unknown sol_view_compilers value")
+        }
+        ld.solViewCompilers[ParseAddress(addr)] = compiler
+    }
+    if ld.SolViewFallback == "" {
+        ld.SolViewFallback = SolViewSolidity
+    }
+    if ld.SolViewFallback != SolViewSolidity && ld.SolViewFallback != SolViewVyper {
+        panic("Unknown sol_view_fallback value")
+    }
     if ld.OutputFormat == "" {
         ld.OutputFormat = "text"
     }
@@ -70,11 +104,33 @@ func NewLoggerDefinition(ld *LoggerDefinition) *LoggerDefinition {
     return ld
 }
 
+// layout of the executed code is preferred, so proxies can be described by implementation layout
+func (ld *LoggerDefinition) StorageLayout(addr Address, codeAddr Address) *StorageLayout {
+    if layout, ok := ld.storageLayouts[codeAddr]; ok {
+        return layout
+    }
+    return ld.storageLayouts[addr]
+}
+
+func (ld *LoggerDefinition) SolViewCompiler(addr Address, codeAddr Address) string {
+    if compiler, ok := ld.solViewCompilers[codeAddr]; ok {
+        return compiler
+    }
+    if compiler, ok := ld.solViewCompilers[addr]; ok {
+        return compiler
+    }
+    return SolViewAuto
+}
+
 func (ld *LoggerDefinition) OpcodeFull(opcode uint8) bool {
     _, ok := ld.opcodesFull[uint64(opcode)]
     return ok
 }
 
+func (ld *LoggerDefinition) JumpTargets() bool {
+    return ld.JumpTargetsFull || ld.JumpTargetsShort
+}
+
 func (ld *LoggerDefinition) OpcodeShort(opcode uint8) bool {
     _, ok := ld.opcodesShort[uint64(opcode)]
     return ok
@@ -91,6 +147,15 @@ type LoggerContext struct {
     codeAddress    Address
     codeHash       Hash
     initcodeHash   Hash
+    callKind       string
+    systemCall     bool
+}
+
+// kind is "abi" (title is signature) or "transfer" (title is recipient)
+type formulaLabels struct {
+    kind   string
+    title  string
+    labels []string
 }
 
 type Logger struct {
@@ -108,58 +173,250 @@ func NewLogger(simpleDB *SimpleDB, toLog LoggerDefinition, writer OutputWriter)
     return l
 }
 
-func (l *Logger) EnterContext(block *big.Int, timestamp uint64, origin Address, txHash Hash) {
-    l.context.block     = block
-    l.context.timestamp = timestamp
-    l.context.origin    = origin
-    l.context.txHash    = txHash
+func (l *Logger) EnterContext(block *big.Int, timestamp uint64, origin Address, txHash Hash, systemCall bool) {
+    l.context.block      = block
+    l.context.timestamp  = timestamp
+    l.context.origin     = origin
+    l.context.txHash     = txHash
+    l.context.systemCall = systemCall
 }
 
-func (l *Logger) SetContractAddress(address Address, addressVersion uint64, codeAddress Address, codeHash, initcodeHash Hash) {
+func (l *Logger) SetContractAddress(address Address, addressVersion uint64, codeAddress Address, codeHash, initcodeHash Hash, callKind string) {
     l.context.address        = address
     l.context.addressVersion = addressVersion
     l.context.codeAddress    = codeAddress
     l.context.codeHash       = codeHash
     l.context.initcodeHash   = initcodeHash
+    l.context.callKind       = callKind
 }
 
 func (l *Logger) LogLog(log Log) {
     eventType := "log"
     fullEnabled := l.toLog.LogsFull
     shortEnabled := l.toLog.LogsShort
-    l.logFormulasWithShorts(eventType, log.addr, log.addrVersion, log.codeAddr, append([]Formula{log.data}, log.topics...), fullEnabled, shortEnabled)
+    formulas := append([]Formula{log.data}, log.topics...)
+    var labels *formulaLabels
+    if len(log.topics) > 0 {
+        if entry, ok := l.toLog.abi.Event(log.topics[0].result); ok {
+            if decoded, decodedLabels, ok := l.decodeLog(entry, log); ok {
+                formulas = decoded
+                labels = decodedLabels
+            }
+        }
+    }
+    l.logFormulasWithShorts(eventType, log.addr, log.addrVersion, log.codeAddr, formulas, labels, log.guards, fullEnabled, shortEnabled)
 }
 
-func (l *Logger) LogReturnData(addr Address, addrVersion uint64, codeAddress Address, val []DEPByte) {
+func (l *Logger) decodeLog(entry AbiEntry, log Log) ([]Formula, *formulaLabels, bool) {
+    indexed := []AbiParam{}
+    nonIndexed := []AbiParam{}
+    for _, input := range entry.Inputs {
+        if input.Indexed {
+            indexed = append(indexed, input)
+        } else {
+            nonIndexed = append(nonIndexed, input)
+        }
+    }
+    if len(indexed) != len(log.topics) - 1 {
+        return nil, nil, false
+    }
+    ranges, ok := abiDecodeRanges(nonIndexed, log.data.result)
+    if !ok {
+        return nil, nil, false
+    }
+
+    formulas := []Formula{}
+    labels := &formulaLabels{"abi", entry.Signature(), []string{}}
+    i, j := 0, 0
+    for _, input := range entry.Inputs {
+        if input.Indexed {
+            formulas = append(formulas, log.topics[1+i])
+            labels.labels = append(labels.labels, input.Name + " (" + input.canonicalType() + " indexed)")
+            i += 1
+        } else {
+            r := ranges[j]
+            formulas = append(formulas, l.simpleDB.FormulaSliceWithShorts(log.data, r.Offset, r.Size))
+            labels.labels = append(labels.labels, input.Name + " (" + input.canonicalType() + ")")
+            j += 1
+        }
+    }
+    return formulas, labels, true
+}
+
+func (l *Logger) LogJumpTarget(jt JumpTarget) {
+    eventType := "jump_target"
+    fullEnabled := l.toLog.JumpTargetsFull
+    shortEnabled := l.toLog.JumpTargetsShort
+    l.logFormulasWithShorts(eventType, jt.addr, jt.addrVersion, jt.codeAddr, []Formula{jt.target}, nil, nil, fullEnabled, shortEnabled)
+}
+
+func (l *Logger) LogValueTransfer(vt ValueTransfer) {
+    eventType := "value_transfer"
+    fullEnabled := l.toLog.ValueTransfersFull
+    shortEnabled := l.toLog.ValueTransfersShort
+    labels := &formulaLabels{"transfer", hex.EncodeToString(vt.to[:]), []string{"amount"}}
+    l.logFormulasWithShorts(eventType, vt.addr, vt.addrVersion, vt.codeAddr, []Formula{vt.amount}, labels, nil, fullEnabled, shortEnabled)
+}
+
+func (l *Logger) LogReturnData(addr Address, addrVersion uint64, codeAddress Address, val []DEPByte, guards []Formula) {
     eventType := "return"
     fullEnabled := l.toLog.ReturnDataFull
     shortEnabled := l.toLog.ReturnDataShort
-    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, fullEnabled, shortEnabled)
+    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, nil, guards, fullEnabled, shortEnabled)
 }
 
 func (l *Logger) LogFinalCode(addr Address, addrVersion uint64, codeAddress Address, val []DEPByte) {
     eventType := "final_code"
     fullEnabled := l.toLog.CodesFull
     shortEnabled := l.toLog.CodesShort
-    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, fullEnabled, shortEnabled)
+    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, nil, nil, fullEnabled, shortEnabled)
 }
 
-func (l *Logger) LogFinalSlot(addr Address, addrVersion uint64, codeAddress Address, val []DEPByte, slot *uint256.Int) {
+func (l *Logger) LogFinalSlot(addr Address, addrVersion uint64, codeAddress Address, val []DEPByte, slot *uint256.Int, guards []Formula) {
 
     eventType := "final_slot"
     fullEnabled := l.toLog.FinalSlotsFull
     shortEnabled := l.toLog.FinalSlotsShort
-    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, fullEnabled, shortEnabled)
+    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, nil, guards, fullEnabled, shortEnabled)
+}
+
+func (l *Logger) LogFinalTransient(addr Address, addrVersion uint64, codeAddress Address, val []DEPByte, slot *uint256.Int, guards []Formula) {
+    eventType := "final_transient"
+    fullEnabled := l.toLog.FinalTransientFull
+    shortEnabled := l.toLog.FinalTransientShort
+    l.logFormulasWithShorts(eventType, addr, addrVersion, codeAddress, []Formula{l.simpleDB.FormulaDepWithShorts(val)}, nil, guards, fullEnabled, shortEnabled)
 }
 
 func (l *Logger) LogOpcode(formula Formula) {
     eventType := "opcode"
     fullEnabled := l.toLog.OpcodeFull(formula.opcode)
     shortEnabled := l.toLog.OpcodeShort(formula.opcode)
-    l.logFormulasWithShorts(eventType, l.context.address, l.context.addressVersion, l.context.codeAddress, []Formula{formula}, fullEnabled, shortEnabled)
+    l.logFormulasWithShorts(eventType, l.context.address, l.context.addressVersion, l.context.codeAddress, []Formula{formula}, nil, nil, fullEnabled, shortEnabled)
+}
+
+// all reads of pre-existing chain state made by transaction, values are valid for its block
+func (l *Logger) LogWitness(addr Address, addrVersion uint64, codeAddr Address, witness []WitnessRead) {
+    eventType := "witness"
+    formulaHashes := []string{}
+    for _, read := range witness {
+        formulaHashes = append(formulaHashes, hex.EncodeToString(read.value.hash[:]))
+    }
+    outputHashes := map[string][]string{"full": formulaHashes}
+
+    if l.toLog.OutputFormat == "text" {
+        if !l.toLog.OmitInfo {
+            l.writer.Println("## INFO")
+            infoJSON, err := json.MarshalIndent(l.info(eventType, addr, addrVersion, codeAddr, outputHashes), "", "  ")
+            if err != nil {
+                panic(err)
+            }
+            l.writer.Println(string(infoJSON))
+        }
+        l.writer.Println("## WITNESS")
+        for _, read := range witness {
+            args := []any{"#", read.kind, hex.EncodeToString(read.addr[:])}
+            if len(read.key) > 0 {
+                args = append(args, hex.EncodeToString(read.key))
+            }
+            args = append(args, "=>", hex.EncodeToString(read.value.result))
+            l.writer.Println(args...)
+        }
+        l.writer.Println()
+    } else if l.toLog.OutputFormat == "json" {
+        res := map[string]any{}
+
+        if !l.toLog.OmitInfo {
+            res["info"] = l.info(eventType, addr, addrVersion, codeAddr, outputHashes)
+        }
+
+        type WitnessJSON struct {
+            Address string `json:"address"`
+            Kind    string `json:"kind"`
+            Key     string `json:"key"`
+            Value   string `json:"value"`
+            Block   string `json:"block"`
+            Formula string `json:"formula"`
+        }
+        reads := []WitnessJSON{}
+        for i, read := range witness {
+            reads = append(reads, WitnessJSON {
+                Address: hex.EncodeToString(read.addr[:]),
+                Kind:    read.kind,
+                Key:     hex.EncodeToString(read.key),
+                Value:   hex.EncodeToString(read.value.result),
+                Block:   l.context.block.String(),
+                Formula: formulaHashes[i],
+            })
+        }
+        res["witness"] = reads
+
+        resJSON, err := json.Marshal(res)
+        if err != nil {
+            panic(err)
+        }
+        l.writer.Println(string(resJSON))
+    }
 }
 
-func (l *Logger) logFormulasWithShorts(eventType string, addr Address, addrVersion uint64, codeAddr Address, formulas []Formula, fullEnabled, shortEnabled bool) {
+// block was reorged out, events of its transactions are no longer valid
+func (l *Logger) LogRetracted(block *big.Int, blockHash Hash, txHashes []Hash) {
+    if !l.toLog.Retracted {
+        return
+    }
+    eventType := "retracted"
+    // event is not related to a transaction, so info has only block
+    type RetractedInfoJSON struct {
+        EventType string `json:"event_type"`
+        Block     string `json:"block"`
+    }
+    info := RetractedInfoJSON {
+        EventType: eventType,
+        Block:     block.String(),
+    }
+    type RetractedJSON struct {
+        BlockHash string   `json:"block_hash"`
+        TxHashes  []string `json:"txhashes"`
+    }
+    retracted := RetractedJSON {
+        BlockHash: hex.EncodeToString(blockHash[:]),
+        TxHashes:  []string{},
+    }
+    for _, txHash := range txHashes {
+        retracted.TxHashes = append(retracted.TxHashes, hex.EncodeToString(txHash[:]))
+    }
+
+    if l.toLog.OutputFormat == "text" {
+        if !l.toLog.OmitInfo {
+            l.writer.Println("## INFO")
+            infoJSON, err := json.MarshalIndent(info, "", "  ")
+            if err != nil {
+                panic(err)
+            }
+            l.writer.Println(string(infoJSON))
+        }
+        l.writer.Println("## RETRACTED")
+        l.writer.Println("#", "block", block.String(), retracted.BlockHash)
+        for _, txHash := range retracted.TxHashes {
+            l.writer.Println("#", "tx", txHash)
+        }
+        l.writer.Println()
+    } else if l.toLog.OutputFormat == "json" {
+        res := map[string]any{}
+
+        if !l.toLog.OmitInfo {
+            res["info"] = info
+        }
+        res["retracted"] = retracted
+
+        resJSON, err := json.Marshal(res)
+        if err != nil {
+            panic(err)
+        }
+        l.writer.Println(string(resJSON))
+    }
+}
+
+func (l *Logger) logFormulasWithShorts(eventType string, addr Address, addrVersion uint64, codeAddr Address, formulas []Formula, labels *formulaLabels, guards []Formula, fullEnabled, shortEnabled bool) {
     outputFormulas := make(map[string][]Formula)
     if fullEnabled {
         outputFormulas["full"] = formulas
@@ -190,15 +447,22 @@ func (l *Logger) logFormulasWithShorts(eventType string, addr Address, addrVersi
         }
     }
     if len(outputFormulas) > 0 {
-        l.logFormulas(eventType, addr, addrVersion, codeAddr, outputFormulas)
+        l.logFormulas(eventType, addr, addrVersion, codeAddr, outputFormulas, labels, guards)
     }
 }
 
-func solidityView(s *SimpleDB, formula Formula, isJson bool) any {
+func solidityView(s *SimpleDB, formula Formula, compiler string, fallback string, layout *StorageLayout, isJson bool) any {
     if formula.opcode != OPSStore && formula.opcode != OPSLoad && formula.opcode != OPTStore && formula.opcode != OPTLoad {
         return nil
     }
-    solView := SolViewNew(s, s.GetFormula(formula.operands[1]))
+    solView := SolViewNew(s, s.GetFormula(formula.operands[1]), compiler, fallback)
+    // storage layout describes only persistent storage
+    var layoutView *SolLayoutView
+    if layout != nil && (formula.opcode == OPSStore || formula.opcode == OPSLoad) {
+        if view, ok := layout.Resolve(solView, s.GetFormula(formula.operands[0]).result); ok {
+            layoutView = view
+        }
+    }
     if !isJson {
         s.writer.Println("## SOLIDITY")
         s.writer.Println(
@@ -209,19 +473,24 @@ func solidityView(s *SimpleDB, formula Formula, isJson bool) any {
             hex.EncodeToString(s.GetFormula(formula.operands[0]).result),
         )
         solView.Print(s.writer)
+        if layoutView != nil {
+            layoutView.Print(s.writer)
+        }
         return nil
     } else {
         type InfoJSON struct {
-            Offsets [][2]string `json:"offsets"`
-            OPCode  string      `json:"opcode"`
-            Key     string      `json:"key"`
-            Value   string      `json:"value"`
+            Offsets [][2]string    `json:"offsets"`
+            OPCode  string         `json:"opcode"`
+            Key     string         `json:"key"`
+            Value   string         `json:"value"`
+            Layout  *SolLayoutView `json:"layout,omitempty"`
         }
         res := InfoJSON {
             Offsets: solView.JSON(),
             OPCode:  strings.ToLower(OpcodeToString[formula.opcode]),
             Key:     hex.EncodeToString(s.GetFormula(formula.operands[1]).result),
             Value:   hex.EncodeToString(s.GetFormula(formula.operands[0]).result),
+            Layout:  layoutView,
         }
         return res
     }
@@ -229,11 +498,61 @@ func solidityView(s *SimpleDB, formula Formula, isJson bool) any {
 
 
 
+func (l *Logger) info(eventType string, addr Address, addrVersion uint64, codeAddr Address, outputHashes map[string][]string) any {
+    var info any
+    if l.toLog.MinimalInfo {
+        type MinimalInfoJSON struct {
+            EventType      string `json:"event_type"`
+            Address        string `json:"address"`
+        }
+
+        info = MinimalInfoJSON {
+            EventType: eventType,
+            Address:   hex.EncodeToString(addr[:]),
+        }
+    } else {
+        type InfoJSON struct {
+            EventType      string `json:"event_type"`
+            ShortTypes     map[string][]string `json:"short_types"`
+            Block          string `json:"block"`
+            TxHash         string `json:"txhash"`
+            Timestamp      uint64 `json:"timestamp"`
+            Origin         string `json:"origin"`
+            Address        string `json:"address"`
+            AddressVersion uint64 `json:"address_version"`
+            CodeAddress    string `json:"code_address"`
+            CodeHash       string `json:"code_hash"`
+            InitcodeHash   string `json:"initcode_hash"`
+            CallKind       string `json:"call_kind"`
+            SystemCall     bool   `json:"system_call"`
+        }
+
+        info = InfoJSON {
+            EventType:      eventType,
+            ShortTypes:     outputHashes,
+            Block:          l.context.block.String(),
+            TxHash:         hex.EncodeToString(l.context.txHash[:]),
+            Timestamp:      l.context.timestamp,
+            Origin:         hex.EncodeToString(l.context.origin[:]),
+            Address:        hex.EncodeToString(addr[:]),
+            AddressVersion: addrVersion,
+            CodeAddress:    hex.EncodeToString(codeAddr[:]),
+            CodeHash:       hex.EncodeToString(l.context.codeHash[:]),
+            InitcodeHash:   hex.EncodeToString(l.context.initcodeHash[:]),
+            CallKind:       l.context.callKind,
+            SystemCall:     l.context.systemCall,
+        }
+    }
+    return info
+}
+
 func (l *Logger) logFormulas(
     eventType string,
     addr Address, addrVersion uint64,
     codeAddr Address,
     outputFormulas map[string][]Formula,
+    labels *formulaLabels,
+    guards []Formula,
 ) {
     outputHashes := make(map[string][]string)
     for shortType, formulas := range outputFormulas {
@@ -245,63 +564,23 @@ func (l *Logger) logFormulas(
         outputHashes[shortType] = formulaHashes
     }
 
-    getInfo := func() any {
-        var info any
-        if l.toLog.MinimalInfo {
-            type MinimalInfoJSON struct {
-                EventType      string `json:"event_type"`
-                Address        string `json:"address"`
-            }
-
-            info = MinimalInfoJSON {
-                EventType: eventType,
-                Address:   hex.EncodeToString(addr[:]),
-            }
-        } else {
-            type InfoJSON struct {
-                EventType      string `json:"event_type"`
-                ShortTypes     map[string][]string `json:"short_types"`
-                Block          string `json:"block"`
-                TxHash         string `json:"txhash"`
-                Timestamp      uint64 `json:"timestamp"`
-                Origin         string `json:"origin"`
-                Address        string `json:"address"`
-                AddressVersion uint64 `json:"address_version"`
-                CodeAddress    string `json:"code_address"`
-                CodeHash       string `json:"code_hash"`
-                InitcodeHash   string `json:"initcode_hash"`
-            }
-
-            info = InfoJSON {
-                EventType:      eventType,
-                ShortTypes:     outputHashes,
-                Block:          l.context.block.String(),
-                TxHash:         hex.EncodeToString(l.context.txHash[:]),
-                Timestamp:      l.context.timestamp,
-                Origin:         hex.EncodeToString(l.context.origin[:]),
-                Address:        hex.EncodeToString(addr[:]),
-                AddressVersion: addrVersion,
-                CodeAddress:    hex.EncodeToString(codeAddr[:]),
-                CodeHash:       hex.EncodeToString(l.context.codeHash[:]),
-                InitcodeHash:   hex.EncodeToString(l.context.initcodeHash[:]),
-            }
-        }
-        return info
-    }
-
     if l.toLog.OutputFormat == "text" {
         if !l.toLog.OmitInfo {
             l.writer.Println("## INFO")
-            infoJSON, err := json.MarshalIndent(getInfo(), "", "  ")
+            infoJSON, err := json.MarshalIndent(l.info(eventType, addr, addrVersion, codeAddr, outputHashes), "", "  ")
             if err != nil {
                 panic(err)
             }
             l.writer.Println(string(infoJSON))
         }
 
+        if labels != nil {
+            l.writer.Println("##", strings.ToUpper(labels.kind), labels.title)
+        }
+
         if l.toLog.SolView && len(outputFormulas["crypto"]) > 0 {
             cryptoFormula := outputFormulas["crypto"][0]
-            solidityView(l.simpleDB, cryptoFormula, false)
+            solidityView(l.simpleDB, cryptoFormula, l.toLog.SolViewCompiler(addr, codeAddr), l.toLog.SolViewFallback, l.toLog.StorageLayout(addr, codeAddr), false)
         }
 
         if !l.toLog.OmitFormulas {
@@ -309,17 +588,21 @@ func (l *Logger) logFormulas(
                 if shortType == "full" {
                     continue
                 }
-                for _, formula := range formulas {
-                    l.writer.Println("##", strings.ToUpper(shortType))
+                for i, formula := range formulas {
+                    l.printFormulaHeader(strings.ToUpper(shortType), labels, i)
                     l.simpleDB.Print(formula)
                 }
             }
             if formulas, ok := outputFormulas["full"]; ok {
-                for _, formula := range formulas {
-                    l.writer.Println("## FULL")
+                for i, formula := range formulas {
+                    l.printFormulaHeader("FULL", labels, i)
                     l.simpleDB.Print(formula)
                 }
             }
+            for i, guard := range guards {
+                l.writer.Println("## GUARD", i)
+                l.simpleDB.Print(guard)
+            }
         }
 
         l.writer.Println()
@@ -327,16 +610,42 @@ func (l *Logger) logFormulas(
         res := map[string]any{}
 
         if !l.toLog.OmitInfo {
-            res["info"] = getInfo()
+            res["info"] = l.info(eventType, addr, addrVersion, codeAddr, outputHashes)
         }
 
         if l.toLog.SolView && len(outputFormulas["crypto"]) > 0 {
             cryptoFormula := outputFormulas["crypto"][0]
-            if view := solidityView(l.simpleDB, cryptoFormula, true); view != nil {
+            if view := solidityView(l.simpleDB, cryptoFormula, l.toLog.SolViewCompiler(addr, codeAddr), l.toLog.SolViewFallback, l.toLog.StorageLayout(addr, codeAddr), true); view != nil {
                 res["solidity"] = view
             }
         }
 
+        if labels != nil && labels.kind == "abi" {
+            type AbiJSON struct {
+                Signature string   `json:"signature"`
+                Params    []string `json:"params"`
+            }
+            res["abi"] = AbiJSON {
+                Signature: labels.title,
+                Params:    labels.labels,
+            }
+        } else if labels != nil && labels.kind == "transfer" {
+            type TransferJSON struct {
+                To string `json:"to"`
+            }
+            res["transfer"] = TransferJSON {
+                To: labels.title,
+            }
+        }
+
+        if len(guards) > 0 {
+            guardHashes := []string{}
+            for _, guard := range guards {
+                guardHashes = append(guardHashes, hex.EncodeToString(guard.hash[:]))
+            }
+            res["guards"] = guardHashes
+        }
+
         // too lazy to implement formulas for now
 
         resJSON, err := json.Marshal(res)
@@ -346,3 +655,11 @@ func (l *Logger) logFormulas(
         l.writer.Println(string(resJSON))
     }
 }
+
+func (l *Logger) printFormulaHeader(shortType string, labels *formulaLabels, i int) {
+    if labels != nil && i < len(labels.labels) {
+        l.writer.Println("##", shortType, labels.labels[i])
+    } else {
+        l.writer.Println("##", shortType)
+    }
+}
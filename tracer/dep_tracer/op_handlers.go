@@ -1,6 +1,7 @@
 package dep_tracer
 
 import (
+    "encoding/binary"
     "github.com/holiman/uint256"
 )
 
@@ -119,6 +120,26 @@ func NewOPHandlers() map[byte]OPHandler {
     new(RevertHandler).Register(handlers)
     new(SelfdestructHandler).Register(handlers)
 
+    new(RJumpHandler).Register(handlers)
+    new(RJumpIHandler).Register(handlers)
+    new(RJumpVHandler).Register(handlers)
+    new(CallFHandler).Register(handlers)
+    new(RetFHandler).Register(handlers)
+    new(JumpFHandler).Register(handlers)
+    new(DupNHandler).Register(handlers)
+    new(SwapNHandler).Register(handlers)
+    new(ExchangeHandler).Register(handlers)
+    new(DataLoadHandler).Register(handlers)
+    new(DataLoadNHandler).Register(handlers)
+    new(DataSizeHandler).Register(handlers)
+    new(DataCopyHandler).Register(handlers)
+    new(ReturnDataLoadHandler).Register(handlers)
+    new(ExtCallHandler).Register(handlers)
+    new(ExtDelegateCallHandler).Register(handlers)
+    new(ExtStaticCallHandler).Register(handlers)
+    new(EOFCreateHandler).Register(handlers)
+    new(ReturnContractHandler).Register(handlers)
+
     return handlers
 }
 
@@ -232,8 +253,7 @@ func (oh *GasHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256
     return DIRECTION_NONE
 }
 func (oh *GasHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
-    DataConstant {
-        Op: OPGas,
+    DataGas {
         Value: stack[stackSize-1],
     }.Handle(db, state)
 }
@@ -248,8 +268,7 @@ func (oh *CallValueHandler) Before(db *SimpleDB, state *TransactionDB, stack []u
     return DIRECTION_NONE
 }
 func (oh *CallValueHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
-    DataConstant {
-        Op: OPCallValue,
+    DataCallValue {
         Value: stack[stackSize-1],
     }.Handle(db, state)
 }
@@ -323,16 +342,14 @@ func (oh *ByteHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
 
 type JumpHandler struct {
-    data DataEmpty
+    data DataJump
 }
 func (oh *JumpHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(JUMP)] = oh
 }
 func (oh *JumpHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
-    oh.data = DataEmpty {
-        N: 1,
-    }
-    
+    oh.data = DataJump {}
+
     return DIRECTION_NONE
 }
 func (oh *JumpHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
@@ -342,16 +359,16 @@ func (oh *JumpHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
 
 type JumpIHandler struct {
-    data DataEmpty
+    data DataJumpI
 }
 func (oh *JumpIHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(JUMPI)] = oh
 }
 func (oh *JumpIHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
-    oh.data = DataEmpty {
-        N: 2,
+    oh.data = DataJumpI {
+        Pc: pc,
     }
-    
+
     return DIRECTION_NONE
 }
 func (oh *JumpIHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
@@ -1032,17 +1049,21 @@ func (oh *ReturnDataSizeHandler) After(db *SimpleDB, state *TransactionDB, stack
 func (oh *ReturnDataSizeHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
 
-type BalanceHandler struct {}
+type BalanceHandler struct {
+    data DataBalance
+}
 func (oh *BalanceHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(BALANCE)] = oh
 }
 func (oh *BalanceHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataBalance {
+        Address: stack[stackSize-1].Bytes20(),
+    }
     return DIRECTION_NONE
 }
 func (oh *BalanceHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
-    DataBalance {
-        Balance: stack[stackSize-1],
-    }.Handle(db, state)
+    oh.data.Balance = stack[stackSize-1]
+    oh.data.Handle(db, state)
 }
 func (oh *BalanceHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
@@ -1178,17 +1199,21 @@ func (oh *ExtCodeHashHandler) After(db *SimpleDB, state *TransactionDB, stack []
 func (oh *ExtCodeHashHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
 
-type BlockHashHandler struct {}
+type BlockHashHandler struct {
+    data DataBlockHash
+}
 func (oh *BlockHashHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(BLOCKHASH)] = oh
 }
 func (oh *BlockHashHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataBlockHash {
+        Number: stack[stackSize-1],
+    }
     return DIRECTION_NONE
 }
 func (oh *BlockHashHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
-    DataBlockHash {
-        Hash: stack[stackSize-1].Bytes32(),
-    }.Handle(db, state)
+    oh.data.Hash = stack[stackSize-1].Bytes32()
+    oh.data.Handle(db, state)
 }
 func (oh *BlockHashHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
@@ -1358,6 +1383,15 @@ func (oh *LogHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.
 func (oh *LogHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
 
 
+// code of EIP-7702 delegate, nil if account code is not delegation
+func delegatedCode(stateDB StateDB, code []byte) []byte {
+    if target, ok := ParseDelegation(code); ok {
+        return stateDB.GetCode(target)
+    }
+    return nil
+}
+
+
 type CallHandler struct {
     DataEnd DataCallEnd
 }
@@ -1365,13 +1399,18 @@ func (oh *CallHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(CALL)] = oh
 }
 func (oh *CallHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-2].Bytes20())
     DataCallStart {
+        Op: op,
         N: 7,
+        HasValue: true,
+        Value: stack[stackSize-3],
         Address: stack[stackSize-2].Bytes20(),
         CodeAddress: stack[stackSize-2].Bytes20(),
         InOffset: stack[stackSize-4].Uint64(),
         InSize: stack[stackSize-5].Uint64(),
-        Code: stateDB.GetCode(stack[stackSize-2].Bytes20()),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
     }.Handle(db, state)
 
     oh.DataEnd = DataCallEnd {
@@ -1395,13 +1434,18 @@ func (oh *CallCodeHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(CALLCODE)] = oh
 }
 func (oh *CallCodeHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-2].Bytes20())
     DataCallStart {
+        Op: op,
         N: 7,
+        HasValue: true,
+        Value: stack[stackSize-3],
         Address: addr,
         CodeAddress: stack[stackSize-2].Bytes20(),
         InOffset: stack[stackSize-4].Uint64(),
         InSize: stack[stackSize-5].Uint64(),
-        Code: stateDB.GetCode(stack[stackSize-2].Bytes20()),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
     }.Handle(db, state)
 
     oh.DataEnd = DataCallEnd {
@@ -1425,13 +1469,17 @@ func (oh *DelegateCallHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(DELEGATECALL)] = oh
 }
 func (oh *DelegateCallHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-2].Bytes20())
     DataCallStart {
+        Op: op,
         N: 6,
+        Delegate: true,
         Address: addr,
         CodeAddress: stack[stackSize-2].Bytes20(),
         InOffset: stack[stackSize-3].Uint64(),
         InSize: stack[stackSize-4].Uint64(),
-        Code: stateDB.GetCode(stack[stackSize-2].Bytes20()),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
     }.Handle(db, state)
 
     oh.DataEnd = DataCallEnd {
@@ -1455,13 +1503,16 @@ func (oh *StaticCallHandler) Register(handlers map[byte]OPHandler) {
     handlers[byte(STATICCALL)] = oh
 }
 func (oh *StaticCallHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-2].Bytes20())
     DataCallStart {
+        Op: op,
         N: 6,
         Address: stack[stackSize-2].Bytes20(),
         CodeAddress: stack[stackSize-2].Bytes20(),
         InOffset: stack[stackSize-3].Uint64(),
         InSize: stack[stackSize-4].Uint64(),
-        Code: stateDB.GetCode(stack[stackSize-2].Bytes20()),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
     }.Handle(db, state)
 
     oh.DataEnd = DataCallEnd {
@@ -1656,3 +1707,390 @@ func (oh *SelfdestructHandler) Before(db *SimpleDB, state *TransactionDB, stack
 }
 func (oh *SelfdestructHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
 func (oh *SelfdestructHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+// EOF (EIP-7692), pc is relative to current code section, immediates are read from container
+
+type RJumpHandler struct {}
+func (oh *RJumpHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(RJUMP)] = oh
+}
+func (oh *RJumpHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    DataRJump{}.Handle(db, state)
+    return DIRECTION_NONE
+}
+func (oh *RJumpHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *RJumpHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type RJumpIHandler struct {}
+func (oh *RJumpIHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(RJUMPI)] = oh
+}
+func (oh *RJumpIHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    DataRJumpI{Pc: pc}.Handle(db, state)
+    return DIRECTION_NONE
+}
+func (oh *RJumpIHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *RJumpIHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type RJumpVHandler struct {}
+func (oh *RJumpVHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(RJUMPV)] = oh
+}
+func (oh *RJumpVHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    DataRJumpV{Pc: pc}.Handle(db, state)
+    return DIRECTION_NONE
+}
+func (oh *RJumpVHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *RJumpVHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type CallFHandler struct {}
+func (oh *CallFHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(CALLF)] = oh
+}
+func (oh *CallFHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    DataCallF {
+        Section: binary.BigEndian.Uint16(state.EOFImmediate(pc, 2)),
+    }.Handle(db, state)
+    return DIRECTION_NONE
+}
+func (oh *CallFHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *CallFHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type RetFHandler struct {}
+func (oh *RetFHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(RETF)] = oh
+}
+func (oh *RetFHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    DataRetF{}.Handle(db, state)
+    return DIRECTION_NONE
+}
+func (oh *RetFHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *RetFHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type JumpFHandler struct {}
+func (oh *JumpFHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(JUMPF)] = oh
+}
+func (oh *JumpFHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    DataJumpF {
+        Section: binary.BigEndian.Uint16(state.EOFImmediate(pc, 2)),
+    }.Handle(db, state)
+    return DIRECTION_NONE
+}
+func (oh *JumpFHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *JumpFHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type DupNHandler struct {
+    data DataDup
+}
+func (oh *DupNHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(DUPN)] = oh
+}
+func (oh *DupNHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataDup {
+        Size: 1 + int(state.EOFImmediate(pc, 1)[0]),
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *DupNHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *DupNHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type SwapNHandler struct {
+    data DataSwap
+}
+func (oh *SwapNHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(SWAPN)] = oh
+}
+func (oh *SwapNHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataSwap {
+        Size: 2 + int64(state.EOFImmediate(pc, 1)[0]),
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *SwapNHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *SwapNHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type ExchangeHandler struct {
+    data DataExchange
+}
+func (oh *ExchangeHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(EXCHANGE)] = oh
+}
+func (oh *ExchangeHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    imm := state.EOFImmediate(pc, 1)[0]
+    n := int(imm >> 4) + 1
+    m := int(imm & 0x0F) + 1
+    oh.data = DataExchange {
+        N: n + 1,
+        M: n + m + 1,
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *ExchangeHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *ExchangeHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type DataLoadHandler struct {
+    data DataDataLoad
+}
+func (oh *DataLoadHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(DATALOAD)] = oh
+}
+func (oh *DataLoadHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataDataLoad {
+        Offset: stack[stackSize-1].Uint64(),
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *DataLoadHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *DataLoadHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type DataLoadNHandler struct {
+    data DataDataLoadN
+}
+func (oh *DataLoadNHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(DATALOADN)] = oh
+}
+func (oh *DataLoadNHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataDataLoadN {
+        Offset: uint64(binary.BigEndian.Uint16(state.EOFImmediate(pc, 2))),
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *DataLoadNHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *DataLoadNHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type DataSizeHandler struct {}
+func (oh *DataSizeHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(DATASIZE)] = oh
+}
+func (oh *DataSizeHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    return DIRECTION_NONE
+}
+func (oh *DataSizeHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    DataDataSize {
+        DataSize: stack[stackSize-1].Uint64(),
+    }.Handle(db, state)
+}
+func (oh *DataSizeHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type DataCopyHandler struct {
+    data DataDataCopy
+}
+func (oh *DataCopyHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(DATACOPY)] = oh
+}
+func (oh *DataCopyHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataDataCopy {
+        MemoryOffset: stack[stackSize-1].Uint64(),
+        DataOffset: stack[stackSize-2].Uint64(),
+        Size: stack[stackSize-3].Uint64(),
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *DataCopyHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *DataCopyHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type ReturnDataLoadHandler struct {
+    data DataReturndataLoad
+}
+func (oh *ReturnDataLoadHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(RETURNDATALOAD)] = oh
+}
+func (oh *ReturnDataLoadHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    oh.data = DataReturndataLoad {
+        Offset: stack[stackSize-1].Uint64(),
+    }
+
+    return DIRECTION_NONE
+}
+func (oh *ReturnDataLoadHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {
+    oh.data.Handle(db, state)
+}
+func (oh *ReturnDataLoadHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
+
+
+type ExtCallHandler struct {
+    DataEnd DataExtCallEnd
+}
+func (oh *ExtCallHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(EXTCALL)] = oh
+}
+func (oh *ExtCallHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-1].Bytes20())
+    DataExtCallStart {
+        Op: op,
+        HasValue: true,
+        Value: stack[stackSize-4],
+        Address: stack[stackSize-1].Bytes20(),
+        CodeAddress: stack[stackSize-1].Bytes20(),
+        InOffset: stack[stackSize-2].Uint64(),
+        InSize: stack[stackSize-3].Uint64(),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
+    }.Handle(db, state)
+
+    oh.DataEnd = DataExtCallEnd {}
+
+    return DIRECTION_CALL
+}
+func (oh *ExtCallHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *ExtCallHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {
+    oh.DataEnd.Success = success
+    oh.DataEnd.Handle(db, state)
+}
+
+
+type ExtDelegateCallHandler struct {
+    DataEnd DataExtCallEnd
+}
+func (oh *ExtDelegateCallHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(EXTDELEGATECALL)] = oh
+}
+func (oh *ExtDelegateCallHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-1].Bytes20())
+    DataExtCallStart {
+        Op: op,
+        Delegate: true,
+        Address: addr,
+        CodeAddress: stack[stackSize-1].Bytes20(),
+        InOffset: stack[stackSize-2].Uint64(),
+        InSize: stack[stackSize-3].Uint64(),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
+    }.Handle(db, state)
+
+    oh.DataEnd = DataExtCallEnd {}
+
+    return DIRECTION_CALL
+}
+func (oh *ExtDelegateCallHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *ExtDelegateCallHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {
+    oh.DataEnd.Success = success
+    oh.DataEnd.Handle(db, state)
+}
+
+
+type ExtStaticCallHandler struct {
+    DataEnd DataExtCallEnd
+}
+func (oh *ExtStaticCallHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(EXTSTATICCALL)] = oh
+}
+func (oh *ExtStaticCallHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    code := stateDB.GetCode(stack[stackSize-1].Bytes20())
+    DataExtCallStart {
+        Op: op,
+        Address: stack[stackSize-1].Bytes20(),
+        CodeAddress: stack[stackSize-1].Bytes20(),
+        InOffset: stack[stackSize-2].Uint64(),
+        InSize: stack[stackSize-3].Uint64(),
+        Code: code,
+        DelegatedCode: delegatedCode(stateDB, code),
+    }.Handle(db, state)
+
+    oh.DataEnd = DataExtCallEnd {}
+
+    return DIRECTION_CALL
+}
+func (oh *ExtStaticCallHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *ExtStaticCallHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {
+    oh.DataEnd.Success = success
+    oh.DataEnd.Handle(db, state)
+}
+
+
+type EOFCreateHandler struct {
+    DataEnd DataEOFCreateEnd
+}
+func (oh *EOFCreateHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(EOFCREATE)] = oh
+}
+func (oh *EOFCreateHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    salt := stack[stackSize-2]
+    newAddr := EOFCreateAddress(addr, salt.Bytes32())
+
+    DataEOFCreateStart {
+        Address: newAddr,
+        Index: state.EOFImmediate(pc, 1)[0],
+        InOffset: stack[stackSize-3].Uint64(),
+        InSize: stack[stackSize-4].Uint64(),
+    }.Handle(db, state)
+
+    oh.DataEnd = DataEOFCreateEnd {
+        Address: newAddr,
+    }
+
+    return DIRECTION_CALL
+}
+func (oh *EOFCreateHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *EOFCreateHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {
+    oh.DataEnd.Handle(db, state)
+}
+
+
+type ReturnContractHandler struct {}
+func (oh *ReturnContractHandler) Register(handlers map[byte]OPHandler) {
+    handlers[byte(RETURNCONTRACT)] = oh
+}
+func (oh *ReturnContractHandler) Before(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) int {
+    index := state.EOFImmediate(pc, 1)[0]
+    offset := stack[stackSize-1].Uint64()
+    size := stack[stackSize-2].Uint64()
+
+    // zero size does not expand memory, so offset may be past its end
+    aux := []byte{}
+    if size > 0 {
+        destOffset := offset + size
+        extraZeros := uint64(0)
+        if destOffset > uint64(len(memory)) {
+            extraZeros = destOffset - uint64(len(memory))
+            destOffset = uint64(len(memory))
+        }
+        aux = append(aux, memory[offset:destOffset]...)
+        aux = append(aux, make([]byte, extraZeros)...)
+    }
+    _, container := state.EOFSubcontainer(index)
+
+    DataReturnContract {
+        Index: index,
+        Offset: offset,
+        Size: size,
+        Result: EOFAppendData(container, aux),
+    }.Handle(db, state)
+
+    return DIRECTION_RETURN
+}
+func (oh *ReturnContractHandler) After(db *SimpleDB, state *TransactionDB, stack []uint256.Int, stackSize int, stateDB StateDB, isSelfdestruct6780 bool, isRandom bool, pc uint64, op byte, addr Address, memory []byte) {}
+func (oh *ReturnContractHandler) Exit(db *SimpleDB, state *TransactionDB, success bool) {}
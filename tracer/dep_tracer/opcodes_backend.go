@@ -26,9 +26,14 @@ const (
     OPCreate2Addr uint8 = 0x15
     OPCallResult  uint8 = 0x16
     OPBlobBaseFee uint8 = 0x17
+    OPEOFCreateAddr  uint8 = 0x18
+    OPExtCallResult  uint8 = 0x19 // status of EXT*CALL: 0 success, 1 revert, 2 failure
+    OPDelegation     uint8 = 0x1A // EIP-7702 delegation designator set by authorization list
 
     OPUnknownCode uint8 = 0x30 // special case of slot not known in the past
     OPUnknownSlot uint8 = 0x31 // special case of code not known in the past
+    OPGenesisCode uint8 = 0x32 // code of account from imported genesis alloc
+    OPGenesisSlot uint8 = 0x33 // slot of account from imported genesis alloc
 
     // Dynamic
     OPSlice           uint8 = 0xA0 // slice some value
@@ -75,6 +80,15 @@ const (
     OPBlake2F         uint8 = 0xC9
     OPBlobHash        uint8 = 0xD0
     OPPointEvaluation uint8 = 0xD1
+    OPCallGas         uint8 = 0xD2 // gas available in callee, depends on gas passed by caller
+    OPBlsG1Add        uint8 = 0xD3
+    OPBlsG1Msm        uint8 = 0xD4
+    OPBlsG2Add        uint8 = 0xD5
+    OPBlsG2Msm        uint8 = 0xD6
+    OPBlsPairing      uint8 = 0xD7
+    OPBlsMapG1        uint8 = 0xD8
+    OPBlsMapG2        uint8 = 0xD9
+    OPPrecompile      uint8 = 0xDA // unknown or custom precompile, 1st operand is its address
 
     // Addressable (1st - value, 2nd - address) / when used as operand - shortened to value
     OPSLoad  uint8 = 0xE0
@@ -25,9 +25,14 @@ var OpcodeToString = map[uint8]string{
     OPCreate2Addr: "CREATE2ADDR",
     OPCallResult:  "CALLRESULT",
     OPBlobBaseFee: "BLOBBASEFEE",
+    OPEOFCreateAddr: "EOFCREATEADDR",
+    OPExtCallResult: "EXTCALLRESULT",
+    OPDelegation:    "DELEGATION",
     
     OPUnknownCode: "UNKNOWNCODE",
     OPUnknownSlot: "UNKNOWNSLOT",
+    OPGenesisCode: "GENESISCODE",
+    OPGenesisSlot: "GENESISSLOT",
 
 	OPSlice:           "SLICE",
 	OPConcat:          "CONCAT",
@@ -73,6 +78,15 @@ var OpcodeToString = map[uint8]string{
     OPBlake2F:         "BLAKE2F",
     OPBlobHash:        "BLOBHASH",
     OPPointEvaluation: "POINTEVALUATION",
+    OPCallGas:         "CALLGAS",
+    OPBlsG1Add:        "BLSG1ADD",
+    OPBlsG1Msm:        "BLSG1MSM",
+    OPBlsG2Add:        "BLSG2ADD",
+    OPBlsG2Msm:        "BLSG2MSM",
+    OPBlsPairing:      "BLSPAIRING",
+    OPBlsMapG1:        "BLSMAPG1",
+    OPBlsMapG2:        "BLSMAPG2",
+    OPPrecompile:      "PRECOMPILE",
 
     OPSLoad:  "SLOAD",
     OPSStore: "SSTORE",
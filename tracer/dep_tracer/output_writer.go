@@ -10,6 +10,7 @@ import (
 type OutputWriter interface {
     Println(args ...any)
     Print(args ...any)
+    Close()
 }
 
 func NewStdoutWriter() *StdoutWriter {
@@ -22,6 +23,7 @@ func (w *StdoutWriter) Println(args ...any) {
 func (w *StdoutWriter) Print(args ...any) {
     fmt.Print(args...)
 }
+func (w *StdoutWriter) Close() {}
 
 func NewFileWriter(path string) *FileWriter {
     f, err := os.Create(path)
@@ -41,6 +43,11 @@ func (w *FileWriter) Println(args ...any) {
 func (w *FileWriter) Print(args ...any) {
     fmt.Fprint(w.f, args...)
 }
+func (w *FileWriter) Close() {
+    if err := w.f.Close(); err != nil {
+        panic(err)
+    }
+}
 
 func NewHttpWriter(url string) *HttpWriter {
     if !strings.HasPrefix(url, "http://") {
@@ -80,6 +87,8 @@ func (w *HttpWriter) Println(args ...any) {
 func (w *HttpWriter) Print(args ...any) {
     w.data = fmt.Append(w.data, args...)
 }
+// handlers are registered in default mux, so server lives until the process exits
+func (w *HttpWriter) Close() {}
 
 func NewCallbackWriter(cw CallbackWriterCallback) *CallbackWriter {
     if cw == nil {
@@ -104,3 +113,50 @@ func (w *CallbackWriter) Print(args ...any) {
     data := fmt.Append([]byte{}, args...)
     w.cw.Write(data)
 }
+func (w *CallbackWriter) Close() {}
+
+// output of the current block is held until the block is final, without block it is written directly
+func NewBlockWriter(writer OutputWriter) *BlockWriter {
+    return &BlockWriter{
+        writer:    writer,
+        buffering: false,
+        data:      []string{},
+    }
+}
+type BlockWriter struct {
+    writer    OutputWriter
+    buffering bool
+    data      []string
+}
+func (w *BlockWriter) Println(args ...any) {
+    if !w.buffering {
+        w.writer.Println(args...)
+        return
+    }
+    w.data = append(w.data, fmt.Sprintln(args...))
+}
+func (w *BlockWriter) Print(args ...any) {
+    if !w.buffering {
+        w.writer.Print(args...)
+        return
+    }
+    w.data = append(w.data, fmt.Sprint(args...))
+}
+func (w *BlockWriter) Start() {
+    w.buffering = true
+}
+func (w *BlockWriter) Flush() {
+    for _, data := range w.data {
+        w.writer.Print(data)
+    }
+    w.Discard()
+}
+func (w *BlockWriter) Discard() {
+    w.buffering = false
+    w.data = []string{}
+}
+// output of unfinished block is dropped
+func (w *BlockWriter) Close() {
+    w.Discard()
+    w.writer.Close()
+}
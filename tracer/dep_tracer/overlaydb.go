@@ -14,6 +14,7 @@ type OverlayDBSlotKey struct {
 type OverlaySlot struct {
     data     []DEPByte
     codeAddr Address
+    guards   []Formula
 }
 
 type OverlayCode struct {
@@ -32,7 +33,8 @@ type OverlayDB struct {
     selfdestruced map[Address]bool
     created       map[Address]bool
     versions      map[Address]uint64
-    transient     map[OverlayDBSlotKey][]DEPByte
+    transient     map[OverlayDBSlotKey]OverlaySlot
+    updatedTransient map[OverlayDBSlotKey]bool
 }
 
 func OverlayDBNew(simpleDB *SimpleDB) *OverlayDB {
@@ -45,6 +47,8 @@ func OverlayDBNew(simpleDB *SimpleDB) *OverlayDB {
     o.selfdestruced = make(map[Address]bool)
     o.created = make(map[Address]bool)
     o.versions = make(map[Address]uint64)
+    o.transient = make(map[OverlayDBSlotKey]OverlaySlot)
+    o.updatedTransient = make(map[OverlayDBSlotKey]bool)
     return o
 }
 
@@ -79,10 +83,14 @@ func (o *OverlayDB) Copy() *OverlayDB {
     for k,v := range o.versions {
         res.versions[k] = v
     }
-    res.transient = make(map[OverlayDBSlotKey][]DEPByte)
+    res.transient = make(map[OverlayDBSlotKey]OverlaySlot)
     for k,v := range o.transient {
         res.transient[k] = v
     }
+    res.updatedTransient = make(map[OverlayDBSlotKey]bool)
+    for k,_ := range o.updatedTransient {
+        res.updatedTransient[k] = true
+    }
     return res
 }
 
@@ -102,14 +110,14 @@ func (o *OverlayDB) GetSlot(addr Address, slot *uint256.Int, value Hash) Overlay
     if ok {
         return val
     }
-    val = OverlaySlot{o.simpleDB.GetSlot(addr, slot, value), Address{}}
+    val = OverlaySlot{o.simpleDB.GetSlot(addr, slot, value), Address{}, nil}
     o.slots[key] = val
     return val
 }
 
-func (o *OverlayDB) SetSlot(addr, codeAddress Address, slot *uint256.Int, val []DEPByte) {
+func (o *OverlayDB) SetSlot(addr, codeAddress Address, slot *uint256.Int, val []DEPByte, guards []Formula) {
     key := OverlayDBSlotKey{addr, *slot}
-    o.slots[key] = OverlaySlot{val, codeAddress}
+    o.slots[key] = OverlaySlot{val, codeAddress, guards}
     o.updatedSlots[key] = true
 }
 
@@ -117,16 +125,17 @@ func (o *OverlayDB) GetTransient(addr Address, slot *uint256.Int) []DEPByte {
     key := OverlayDBSlotKey{addr, *slot}
     val, ok := o.transient[key]
     if ok {
-        return val
+        return val.data
     }
-    val = InitDEPBytes(32)
+    val = OverlaySlot{InitDEPBytes(32), Address{}, nil}
     o.transient[key] = val
-    return val
+    return val.data
 }
 
-func (o *OverlayDB) SetTransient(addr Address, slot *uint256.Int, val []DEPByte) {
+func (o *OverlayDB) SetTransient(addr, codeAddress Address, slot *uint256.Int, val []DEPByte, guards []Formula) {
     key := OverlayDBSlotKey{addr, *slot}
-    o.transient[key] = val
+    o.transient[key] = OverlaySlot{val, codeAddress, guards}
+    o.updatedTransient[key] = true
 }
 
 func (o *OverlayDB) GetCode(addr Address, code []byte) OverlayCode {
@@ -146,6 +155,12 @@ func (o *OverlayDB) SetCode(addr, codeAddress Address, val []DEPByte, valBytes [
     o.created[addr] = true
 }
 
+// authorization of EIP-7702, account is not created by it
+func (o *OverlayDB) SetDelegation(addr Address, val []DEPByte, valBytes []byte) {
+    o.codes[addr] = OverlayCode{val, addr, CodeHash(valBytes), Hash{}}
+    o.updatedCodes[addr] = true
+}
+
 func (o *OverlayDB) Destruct(addr Address) {
     o.selfdestruced[addr] = true
 }
@@ -159,8 +174,20 @@ func (o *OverlayDB) Commit() {
     for k,_ := range o.updatedSlots {
         value := o.slots[k]
         o.simpleDB.CommitDEPBytesWithShorts(value.data)
+        for _, guard := range value.guards {
+            o.simpleDB.CommitFormulaWithShorts(guard.hash)
+        }
         o.simpleDB.SetSlot(k.addr, &k.slot, value.data)
-        o.simpleDB.logger.LogFinalSlot(k.addr, o.GetAddressVersion(k.addr), value.codeAddr, value.data, &k.slot)
+        o.simpleDB.logger.LogFinalSlot(k.addr, o.GetAddressVersion(k.addr), value.codeAddr, value.data, &k.slot, value.guards)
+    }
+    // transient storage is discarded at the end of transaction, so it is only logged
+    for k,_ := range o.updatedTransient {
+        value := o.transient[k]
+        o.simpleDB.CommitDEPBytesWithShorts(value.data)
+        for _, guard := range value.guards {
+            o.simpleDB.CommitFormulaWithShorts(guard.hash)
+        }
+        o.simpleDB.logger.LogFinalTransient(k.addr, o.GetAddressVersion(k.addr), value.codeAddr, value.data, &k.slot, value.guards)
     }
     for addr, _ := range o.updatedCodes {
         code := o.codes[addr]
@@ -168,6 +195,9 @@ func (o *OverlayDB) Commit() {
         o.simpleDB.SetCode(addr, code.data, code.codeHash, code.initcodeHash)
         o.simpleDB.logger.LogFinalCode(addr, o.GetAddressVersion(addr), code.codeAddr, code.data)
     }
+    for addr, _ := range o.created {
+        o.simpleDB.SetKnownSince(addr, o.GetAddressVersion(addr))
+    }
     for addr, _ := range o.selfdestruced {
         o.simpleDB.IncreaseAddressVersion(addr)
     }
@@ -22,10 +22,27 @@ func NewPrecompileHandlers() map[Address]PrecompileHandler {
     new(EcPairingHandler).Register(handlers)
     new(Blake2FHandler).Register(handlers)
     new(PointEvaluationHandler).Register(handlers)
+    new(BlsG1AddHandler).Register(handlers)
+    new(BlsG1MsmHandler).Register(handlers)
+    new(BlsG2AddHandler).Register(handlers)
+    new(BlsG2MsmHandler).Register(handlers)
+    new(BlsPairingHandler).Register(handlers)
+    new(BlsMapG1Handler).Register(handlers)
+    new(BlsMapG2Handler).Register(handlers)
 
     return handlers
 }
 
+// precompile configured by user, input is split into operands by layout (sizes in bytes)
+func NewExtraPrecompileHandler(address Address, layout []uint64) *ExtraPrecompileHandler {
+    for _, size := range layout {
+        if size == 0 {
+            panic("zero size in precompile layout")
+        }
+    }
+    return &ExtraPrecompileHandler{address, layout}
+}
+
 func addressify(data []byte) Address {
     val := append(make([]byte, 20-len(data)), data...)
     var res Address
@@ -172,3 +189,96 @@ func (ph *PointEvaluationHandler) Execute(db *SimpleDB, state *TransactionDB, in
         Result: output,
     }.Handle(db, state)
 }
+
+
+type BlsG1AddHandler struct {}
+func (ph *BlsG1AddHandler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0xB})] = ph
+}
+func (ph *BlsG1AddHandler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsG1Add {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type BlsG1MsmHandler struct {}
+func (ph *BlsG1MsmHandler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0xC})] = ph
+}
+func (ph *BlsG1MsmHandler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsG1Msm {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type BlsG2AddHandler struct {}
+func (ph *BlsG2AddHandler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0xD})] = ph
+}
+func (ph *BlsG2AddHandler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsG2Add {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type BlsG2MsmHandler struct {}
+func (ph *BlsG2MsmHandler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0xE})] = ph
+}
+func (ph *BlsG2MsmHandler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsG2Msm {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type BlsPairingHandler struct {}
+func (ph *BlsPairingHandler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0xF})] = ph
+}
+func (ph *BlsPairingHandler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsPairing {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type BlsMapG1Handler struct {}
+func (ph *BlsMapG1Handler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0x10})] = ph
+}
+func (ph *BlsMapG1Handler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsMapG1 {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type BlsMapG2Handler struct {}
+func (ph *BlsMapG2Handler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[addressify([]byte{0x11})] = ph
+}
+func (ph *BlsMapG2Handler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileBlsMapG2 {
+        Result: output,
+    }.Handle(db, state)
+}
+
+
+type ExtraPrecompileHandler struct {
+    address Address
+    layout  []uint64
+}
+func (ph *ExtraPrecompileHandler) Register(handlers map[Address]PrecompileHandler) {
+    handlers[ph.address] = ph
+}
+func (ph *ExtraPrecompileHandler) Execute(db *SimpleDB, state *TransactionDB, input, output []byte) {
+    DataPrecompileUnknown {
+        Address: ph.address,
+        Layout: ph.layout,
+        Result: output,
+    }.Handle(db, state)
+}
@@ -0,0 +1,349 @@
+package dep_tracer
+
+import (
+    "io"
+    "os"
+    "fmt"
+    "bytes"
+    "bufio"
+    "math/big"
+    "encoding/hex"
+    "encoding/json"
+    "github.com/holiman/uint256"
+)
+
+// input events of DepHandler are recorded as json lines, so that they can be replayed offline,
+// state reads are written as separate lines right after the event which caused them
+
+type HexBytes []byte
+
+func (b HexBytes) MarshalText() ([]byte, error) {
+    return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *HexBytes) UnmarshalText(p []byte) error {
+    res, err := hex.DecodeString(string(p))
+    if err != nil {
+        return err
+    }
+    *b = res
+    return nil
+}
+
+const (
+    RecordBlockStart    = "block_start"
+    RecordBlockEnd      = "block_end"
+    RecordStart         = "start"
+    RecordEnd           = "end"
+    RecordAbort         = "abort"
+    RecordAuthorization = "authorization"
+    RecordOpcode        = "opcode"
+    RecordEnter         = "enter"
+    RecordFault         = "fault"
+    RecordExit          = "exit"
+    RecordGetNonce      = "get_nonce"
+    RecordGetCode       = "get_code"
+)
+
+// fields are used depending on kind, stack and memory of opcode are deltas to previous opcode
+type RecordedEvent struct {
+    Kind               string        `json:"kind"`
+    IsCreate           bool          `json:"is_create,omitempty"`
+    Address            HexBytes      `json:"address,omitempty"`
+    Input              HexBytes      `json:"input,omitempty"`
+    Block              *big.Int      `json:"block,omitempty"`
+    BlockHash          HexBytes      `json:"block_hash,omitempty"`
+    ParentHash         HexBytes      `json:"parent_hash,omitempty"`
+    Timestamp          uint64        `json:"timestamp,omitempty"`
+    Origin             HexBytes      `json:"origin,omitempty"`
+    TxHash             HexBytes      `json:"tx_hash,omitempty"`
+    Code               HexBytes      `json:"code,omitempty"`
+    IsSelfdestruct6780 bool          `json:"is_selfdestruct6780,omitempty"`
+    IsRandom           bool          `json:"is_random,omitempty"`
+    IsSystemCall       bool          `json:"is_system_call,omitempty"`
+    Delegate           HexBytes      `json:"delegate,omitempty"`
+    StackKeep          int           `json:"stack_keep,omitempty"`
+    StackPush          []uint256.Int `json:"stack_push,omitempty"`
+    MemorySize         int           `json:"memory_size,omitempty"`
+    MemoryOffset       int           `json:"memory_offset,omitempty"`
+    MemoryData         HexBytes      `json:"memory_data,omitempty"`
+    Pc                 uint64        `json:"pc,omitempty"`
+    Op                 byte          `json:"op,omitempty"`
+    IsInvalid          bool          `json:"is_invalid,omitempty"`
+    HasError           bool          `json:"has_error,omitempty"`
+    Output             HexBytes      `json:"output,omitempty"`
+    Nonce              uint64        `json:"nonce,omitempty"`
+}
+
+type Recorder struct {
+    f          *os.File
+    w          *bufio.Writer
+    prevStack  []uint256.Int
+    prevMemory []byte
+}
+
+func NewRecorder(path string) *Recorder {
+    f, err := os.Create(path)
+    if err != nil {
+        panic(err)
+    }
+    return &Recorder{
+        f: f,
+        w: bufio.NewWriter(f),
+    }
+}
+
+func (r *Recorder) Record(event RecordedEvent) {
+    data, err := json.Marshal(event)
+    if err != nil {
+        panic(err)
+    }
+    r.w.Write(data)
+    r.w.WriteByte('\n')
+}
+
+func (r *Recorder) Flush() {
+    if err := r.w.Flush(); err != nil {
+        panic(err)
+    }
+}
+
+func (r *Recorder) Close() {
+    r.Flush()
+    if err := r.f.Close(); err != nil {
+        panic(err)
+    }
+}
+
+// deferred by DepHandler, so that the event which caused panic is in the file
+func (r *Recorder) FlushOnPanic() {
+    if p := recover(); p != nil {
+        r.w.Flush()
+        panic(p)
+    }
+}
+
+func (r *Recorder) RecordStart(data DataStart, isSelfdestruct6780, isRandom bool) {
+    r.prevStack = nil
+    r.prevMemory = nil
+    r.Record(RecordedEvent{
+        Kind: RecordStart,
+        IsCreate: data.IsCreate,
+        Address: data.Address[:],
+        Input: data.Input,
+        Block: data.Block,
+        Timestamp: data.Timestamp,
+        Origin: data.Origin[:],
+        TxHash: data.TxHash[:],
+        Code: data.Code,
+        IsSelfdestruct6780: isSelfdestruct6780,
+        IsRandom: isRandom,
+        IsSystemCall: data.IsSystemCall,
+    })
+}
+
+func (r *Recorder) RecordOpcode(stack []uint256.Int, memory []byte, addr Address, pc uint64, op byte, isInvalid, hasError bool) {
+    keep := 0
+    for keep < len(stack) && keep < len(r.prevStack) && stack[keep] == r.prevStack[keep] {
+        keep++
+    }
+
+    // memory is compared with previous one resized to the new size (expansion is zeroed)
+    start, end := len(memory), 0
+    for i, b := range memory {
+        var prev byte
+        if i < len(r.prevMemory) {
+            prev = r.prevMemory[i]
+        }
+        if b != prev {
+            if i < start {
+                start = i
+            }
+            end = i + 1
+        }
+    }
+    var memoryData []byte
+    if start < end {
+        memoryData = memory[start:end]
+    } else {
+        start = 0
+    }
+
+    r.Record(RecordedEvent{
+        Kind: RecordOpcode,
+        Address: addr[:],
+        StackKeep: keep,
+        StackPush: stack[keep:],
+        MemorySize: len(memory),
+        MemoryOffset: start,
+        MemoryData: memoryData,
+        Pc: pc,
+        Op: op,
+        IsInvalid: isInvalid,
+        HasError: hasError,
+    })
+
+    r.prevStack = append(r.prevStack[:0], stack...)
+    r.prevMemory = append(r.prevMemory[:0], memory...)
+}
+
+func (r *Recorder) StateDB(stateDB StateDB) StateDB {
+    return &recordingStateDB{stateDB, r}
+}
+
+type recordingStateDB struct {
+    stateDB  StateDB
+    recorder *Recorder
+}
+
+func (s *recordingStateDB) GetNonce(addr [20]byte) uint64 {
+    nonce := s.stateDB.GetNonce(addr)
+    s.recorder.Record(RecordedEvent{Kind: RecordGetNonce, Address: addr[:], Nonce: nonce})
+    return nonce
+}
+
+func (s *recordingStateDB) GetCode(addr [20]byte) []byte {
+    code := s.stateDB.GetCode(addr)
+    s.recorder.Record(RecordedEvent{Kind: RecordGetCode, Address: addr[:], Code: code})
+    return code
+}
+
+
+// answers state reads in the recorded order
+type replayStateDB struct {
+    reads []RecordedEvent
+}
+
+func (s *replayStateDB) next(kind string, addr [20]byte) RecordedEvent {
+    if len(s.reads) == 0 {
+        panic(fmt.Sprintf("replay: unexpected %s of %s", kind, hex.EncodeToString(addr[:])))
+    }
+    read := s.reads[0]
+    s.reads = s.reads[1:]
+    if read.Kind != kind || !bytes.Equal(read.Address, addr[:]) {
+        panic(fmt.Sprintf("replay: expected %s of %s, got %s of %s", read.Kind, hex.EncodeToString(read.Address), kind, hex.EncodeToString(addr[:])))
+    }
+    return read
+}
+
+func (s *replayStateDB) GetNonce(addr [20]byte) uint64 {
+    return s.next(RecordGetNonce, addr).Nonce
+}
+
+func (s *replayStateDB) GetCode(addr [20]byte) []byte {
+    return s.next(RecordGetCode, addr).Code
+}
+
+func toAddress(b []byte) Address {
+    var res Address
+    copy(res[:], b)
+    return res
+}
+
+func toHash(b []byte) Hash {
+    var res Hash
+    copy(res[:], b)
+    return res
+}
+
+// feeds recorded events into handler, transactions which do not match its filter are skipped
+func Replay(handler *DepHandler, r io.Reader) {
+    reader := bufio.NewReader(r)
+    readEvent := func() (RecordedEvent, bool) {
+        line, err := reader.ReadBytes('\n')
+        if err == io.EOF && len(line) == 0 {
+            return RecordedEvent{}, false
+        }
+        if err != nil && err != io.EOF {
+            panic(err)
+        }
+        var event RecordedEvent
+        if err := json.Unmarshal(line, &event); err != nil {
+            panic(fmt.Errorf("replay: failed to parse event: %v", err))
+        }
+        return event, true
+    }
+
+    stateDB := &replayStateDB{}
+    var stack []uint256.Int
+    var memory []byte
+    skipping := false
+
+    event, ok := readEvent()
+    for ok {
+        // reads caused by event follow it
+        next, nextOk := readEvent()
+        for nextOk && (next.Kind == RecordGetNonce || next.Kind == RecordGetCode) {
+            stateDB.reads = append(stateDB.reads, next)
+            next, nextOk = readEvent()
+        }
+
+        if skipping && event.Kind != RecordEnd && event.Kind != RecordAbort {
+            stateDB.reads = nil
+            event, ok = next, nextOk
+            continue
+        }
+
+        switch event.Kind {
+        case RecordBlockStart:
+            handler.StartBlock(event.Block, toHash(event.BlockHash), toHash(event.ParentHash))
+        case RecordBlockEnd:
+            handler.EndBlock(event.HasError)
+        case RecordStart:
+            stack = nil
+            memory = nil
+            if !handler.ShouldRecordTransaction(toAddress(event.Address), toAddress(event.Origin), event.Block) {
+                skipping = true
+                break
+            }
+            if event.IsSystemCall {
+                handler.StartSystemCallRecording(
+                    toAddress(event.Address), event.Input, event.Block, event.Timestamp,
+                    event.Code, event.IsSelfdestruct6780, event.IsRandom, stateDB,
+                )
+                break
+            }
+            handler.StartTransactionRecording(
+                event.IsCreate, toAddress(event.Address), event.Input, event.Block,
+                event.Timestamp, toAddress(event.Origin), toHash(event.TxHash),
+                event.Code, event.IsSelfdestruct6780, event.IsRandom, stateDB,
+            )
+        case RecordEnd:
+            if skipping {
+                skipping = false
+                break
+            }
+            handler.EndTransactionRecording()
+        case RecordAbort:
+            skipping = false
+            handler.AbortTransaction()
+        case RecordAuthorization:
+            handler.HandleAuthorization(toAddress(event.Address), toAddress(event.Delegate))
+        case RecordOpcode:
+            stack = append(stack[:event.StackKeep], event.StackPush...)
+            if event.MemorySize <= len(memory) {
+                memory = memory[:event.MemorySize]
+            } else {
+                memory = append(memory, make([]byte, event.MemorySize-len(memory))...)
+            }
+            copy(memory[event.MemoryOffset:], event.MemoryData)
+            handler.HandleOpcode(
+                append([]uint256.Int{}, stack...), append([]byte{}, memory...), toAddress(event.Address),
+                event.Pc, event.Op, event.IsInvalid, event.HasError,
+            )
+        case RecordEnter:
+            handler.HandleEnter(toAddress(event.Address), event.Input)
+        case RecordFault:
+            handler.HandleFault(event.Op)
+        case RecordExit:
+            handler.HandleExit(event.Output, event.HasError)
+        default:
+            panic(fmt.Sprintf("replay: unknown event %s", event.Kind))
+        }
+        if len(stateDB.reads) > 0 {
+            panic(fmt.Sprintf("replay: %d state reads are not consumed by %s", len(stateDB.reads), event.Kind))
+        }
+
+        event, ok = next, nextOk
+    }
+}
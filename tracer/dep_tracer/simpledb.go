@@ -19,14 +19,21 @@ type SimpleDB struct {
     formulasWithShorts map[Hash]*CommitFormula
     formulas           map[Hash]*CommitFormula
     formulasDB         DB
+    // kv instances shared by formulas and shorts, by name
+    kvInstances        map[string]DB
     slotsDB            DB
     codesDB            DB
     codeHashesDB       DB
     versionsDB         DB
+    journal            *Journal
+    blockWriter        *BlockWriter
     shorts             []*Shorterner
     logger             Logger
     writer             OutputWriter
     pastUnknown        bool
+    pastUnknownHybrid  bool
+    implicitFlow       bool
+    callContext        bool
 }
 
 func CodeHash(code []byte) Hash {
@@ -48,6 +55,12 @@ func codeHashLocation(addr Address, version uint64) []byte {
     return res
 }
 
+func knownSinceLocation(addr Address) []byte {
+    res := addr[:]
+    res = append(res, []byte("known_since")...)
+    return res
+}
+
 func codeLocation(addr Address, version uint64, pos uint64) []byte {
     res := addr[:]
     res = binary.BigEndian.AppendUint64(res, version)
@@ -55,11 +68,19 @@ func codeLocation(addr Address, version uint64, pos uint64) []byte {
     return res
 }
 
+// tracking modes of SimpleDB, see config options of the same names
+type SimpleDBOptions struct {
+    PastUnknown       bool
+    PastUnknownHybrid bool
+    ImplicitFlow      bool
+    CallContext       bool
+}
+
 func SimpleDBNew(
     protectedDifinitions []ProtectedDefinition,
     toLog LoggerDefinition,
     kvEngine, kvRoot string,
-    pastUnknown bool,
+    options SimpleDBOptions,
     writer OutputWriter,
 ) *SimpleDB {
     s := new(SimpleDB)
@@ -74,6 +95,7 @@ func SimpleDBNew(
         single_instances[formulasName] = s.formulasDB
     }
 
+    s.kvInstances = single_instances
     s.shorts = make([]*Shorterner, 0)
     for _, def := range protectedDifinitions {
         s.shorts = append(s.shorts, NewShorterner(s, kvEngine, kvRoot, single_instances, def))
@@ -81,19 +103,38 @@ func SimpleDBNew(
     
     s.ResetFormulas()
 
-    s.slotsDB      = NewDB(kvEngine, kvRoot, "slots")
-    s.codesDB      = NewDB(kvEngine, kvRoot, "codes")
-    s.codeHashesDB = NewDB(kvEngine, kvRoot, "code_hashes")
-    s.versionsDB   = NewDB(kvEngine, kvRoot, "versions")
+    // formulas are addressed by hash, so only state is written per block
+    s.journal      = NewJournal(NewDB(kvEngine, kvRoot, "journal"))
+    s.slotsDB      = NewBlockDB(NewDB(kvEngine, kvRoot, "slots"), s.journal)
+    s.codesDB      = NewBlockDB(NewDB(kvEngine, kvRoot, "codes"), s.journal)
+    s.codeHashesDB = NewBlockDB(NewDB(kvEngine, kvRoot, "code_hashes"), s.journal)
+    s.versionsDB   = NewBlockDB(NewDB(kvEngine, kvRoot, "versions"), s.journal)
 
-    s.logger = NewLogger(s, toLog, writer)
-    s.writer = writer
+    s.blockWriter = NewBlockWriter(writer)
+    s.logger = NewLogger(s, toLog, s.blockWriter)
+    s.writer = s.blockWriter
 
-    s.pastUnknown = pastUnknown
+    s.pastUnknown = options.PastUnknown
+    s.pastUnknownHybrid = options.PastUnknownHybrid
+    s.implicitFlow = options.ImplicitFlow
+    s.callContext = options.CallContext
 
     return s
 }
 
+// closes kv instances and output, pending block is dropped
+func (s *SimpleDB) Close() {
+    for _, db := range s.kvInstances {
+        db.Close()
+    }
+    s.slotsDB.Close()
+    s.codesDB.Close()
+    s.codeHashesDB.Close()
+    s.versionsDB.Close()
+    s.journal.Close()
+    s.blockWriter.Close()
+}
+
 func (s *SimpleDB) CommitDEPBytes(data []DEPByte) {
     prevFormula := Hash{}
     for _, b := range data {
@@ -252,6 +293,20 @@ func (s *SimpleDB) FormulaNew(opcode uint8, result []byte, operands []Hash) Form
 }
 
 func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
+    return s.formulaSlice(formula, offset, size, false)
+}
+
+func (s *SimpleDB) FormulaSliceWithShorts(formula Formula, offset, size uint64) Formula {
+    return s.formulaSlice(formula, offset, size, true)
+}
+
+// formulas with shorts are also saved into shorts, otherwise slicing is the same
+func (s *SimpleDB) formulaSlice(formula Formula, offset, size uint64, withShorts bool) Formula {
+    getFormula, constantNew, formulaNew := s.GetFormula, s.ConstantNew, s.FormulaNew
+    if withShorts {
+        getFormula, constantNew, formulaNew = s.GetFormulaWithShorts, s.ConstantNewWithShorts, s.FormulaNewWithShorts
+    }
+
     totalSize := uint64(len(formula.result))
 
     offset1 := offset + size
@@ -260,7 +315,7 @@ func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
     }
 
     if size == 0 {
-        return s.FormulaNew(OPConcat, []byte{}, []Hash{})
+        return formulaNew(OPConcat, []byte{}, []Hash{})
     }
 
     switch(formula.opcode) {
@@ -269,7 +324,7 @@ func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
         hash_parts := []Hash{}
         i := uint64(0)
         for _, formulaHash := range formula.operands {
-            formula1 := s.GetFormula(formulaHash)
+            formula1 := getFormula(formulaHash)
             j := i + uint64(len(formula1.result))
             if j >= offset {
                 if i >= offset && j <= offset1 {
@@ -281,7 +336,7 @@ func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
                     le := offset - i
                     si := offset1 - offset
                     if si > 0 {
-                        fo := s.FormulaSlice(formula1, le, si)
+                        fo := s.formulaSlice(formula1, le, si, withShorts)
                         byte_parts = append(byte_parts, fo.result...)
                         hash_parts = append(hash_parts, fo.hash)
                     }
@@ -290,7 +345,7 @@ func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
                     le := offset - i
                     si := j - offset
                     if si > 0 {
-                        fo := s.FormulaSlice(formula1, le, si)
+                        fo := s.formulaSlice(formula1, le, si, withShorts)
                         byte_parts = append(byte_parts, fo.result...)
                         hash_parts = append(hash_parts, fo.hash)
                     }
@@ -298,7 +353,7 @@ func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
                     le := uint64(0)
                     si := offset1 - i
                     if si > 0 {
-                        fo := s.FormulaSlice(formula1, le, si)
+                        fo := s.formulaSlice(formula1, le, si, withShorts)
                         byte_parts = append(byte_parts, fo.result...)
                         hash_parts = append(hash_parts, fo.hash)
                     }
@@ -312,30 +367,30 @@ func (s *SimpleDB) FormulaSlice(formula Formula, offset, size uint64) Formula {
             i = j
         }
         if len(hash_parts) == 1 {
-            return s.GetFormula(hash_parts[0])
+            return getFormula(hash_parts[0])
         }
-        return s.FormulaNew(OPConcat, byte_parts, hash_parts)
+        return formulaNew(OPConcat, byte_parts, hash_parts)
     case OPSlice:
         // modify slice
-        prevOffsetOpVal := s.GetFormula(formula.operands[1]).result
+        prevOffsetOpVal := getFormula(formula.operands[1]).result
         prevOffset := binary.BigEndian.Uint64(prevOffsetOpVal)
 
         offsetOpVal := binary.BigEndian.AppendUint64([]byte{}, prevOffset + offset)
-        offsetOp := s.ConstantNew(OPConstant, offsetOpVal)
+        offsetOp := constantNew(OPConstant, offsetOpVal)
 
         sizeOpVal := binary.BigEndian.AppendUint64([]byte{}, size)
-        sizeOp := s.ConstantNew(OPConstant, sizeOpVal)
+        sizeOp := constantNew(OPConstant, sizeOpVal)
 
-        return s.FormulaNew(OPSlice, formula.result[offset:offset1], []Hash{formula.operands[0], offsetOp.hash, sizeOp.hash})
+        return formulaNew(OPSlice, formula.result[offset:offset1], []Hash{formula.operands[0], offsetOp.hash, sizeOp.hash})
     default:
         // make slice
         offsetOpVal := binary.BigEndian.AppendUint64([]byte{}, offset)
-        offsetOp := s.ConstantNew(OPConstant, offsetOpVal)
+        offsetOp := constantNew(OPConstant, offsetOpVal)
 
         sizeOpVal := binary.BigEndian.AppendUint64([]byte{}, size)
-        sizeOp := s.ConstantNew(OPConstant, sizeOpVal)
+        sizeOp := constantNew(OPConstant, sizeOpVal)
 
-        return s.FormulaNew(OPSlice, formula.result[offset:offset1], []Hash{formula.hash, offsetOp.hash, sizeOp.hash})
+        return formulaNew(OPSlice, formula.result[offset:offset1], []Hash{formula.hash, offsetOp.hash, sizeOp.hash})
     }
 }
 
@@ -467,11 +522,44 @@ func (s *SimpleDB) GetAddressVersion(addr Address) uint64 {
     return binary.BigEndian.Uint64(val)
 }
 
+// storage of destructed account is empty, so the new version is fully known
 func (s *SimpleDB) IncreaseAddressVersion(addr Address) {
     version := s.GetAddressVersion(addr) + 1
     versionBin := []byte{}
     versionBin = binary.BigEndian.AppendUint64(versionBin, version)
     s.versionsDB.Set(addr[:], versionBin)
+    s.SetKnownSince(addr, version)
+}
+
+// account is fully known (created or imported) since version, it is tracked even without hybrid
+// mode, so that it can be enabled later
+func (s *SimpleDB) SetKnownSince(addr Address, version uint64) {
+    if _, ok := s.knownSince(addr); ok {
+        return
+    }
+    versionBin := []byte{}
+    versionBin = binary.BigEndian.AppendUint64(versionBin, version)
+    s.versionsDB.Set(knownSinceLocation(addr), versionBin)
+}
+
+func (s *SimpleDB) knownSince(addr Address) (uint64, bool) {
+    val := s.versionsDB.Get(knownSinceLocation(addr), true)
+    if val == nil {
+        return 0, false
+    }
+    return binary.BigEndian.Uint64(val), true
+}
+
+// in hybrid mode only accounts which were not fully known since creation are unknown
+func (s *SimpleDB) PastUnknown(addr Address, version uint64) bool {
+    if !s.pastUnknown {
+        return false
+    }
+    if !s.pastUnknownHybrid {
+        return true
+    }
+    since, ok := s.knownSince(addr)
+    return !ok || version < since
 }
 
 func (s *SimpleDB) GetSlot(addr Address, slot *uint256.Int, value Hash) []DEPByte {
@@ -485,7 +573,7 @@ func (s *SimpleDB) GetSlot(addr Address, slot *uint256.Int, value Hash) []DEPByt
         }
     }
     if len(res) == 0 {
-        if s.pastUnknown {
+        if s.PastUnknown(addr, version) {
             return FormulaDEPBytes(s.ConstantNewWithShorts(OPUnknownSlot, value[:]))
         } else {
             return InitDEPBytes(32)
@@ -520,7 +608,7 @@ func (s *SimpleDB) GetCode(addr Address, code []byte) (Hash, Hash, []DEPByte) {
         copy(initcodeHash[:], codeHashData[32:])
     }
 
-    if s.pastUnknown && len(code) > 0 {
+    if s.PastUnknown(addr, version) && len(code) > 0 {
         location := codeLocation(addr, version, 0)
         val := s.codesDB.Get(location, true)
         if val == nil {
@@ -569,7 +657,7 @@ func (s *SimpleDB) Print(f Formula) {
     fun = func(f1 *Formula, offset int) string {
         res := ""
         if f1.IsConstant() {
-            res += strings.Repeat("    ", offset) + OpcodeToString[f1.opcode] + "(0x" + hex.EncodeToString(f1.result) + ")\n"
+            res += strings.Repeat("    ", offset) + OpcodeToString[f1.opcode] + "(0x" + hex.EncodeToString(f1.result) + ")" + s.calldataLabel(f1) + "\n"
             return res
         }
         if len(f1.operands) < 1 {
@@ -578,7 +666,7 @@ func (s *SimpleDB) Print(f Formula) {
         }
         h0 := Hash{}
         repeated := 0
-        res += strings.Repeat("    ", offset) + OpcodeToString[f1.opcode] + "( # 0x" + hex.EncodeToString(f1.result) + "\n"
+        res += strings.Repeat("    ", offset) + OpcodeToString[f1.opcode] + "( # 0x" + hex.EncodeToString(f1.result) + s.calldataLabel(f1) + "\n"
         for i, h1 := range f1.operands {
             offset += 1
             if (h0 == h1 && i > 0) {
@@ -604,6 +692,34 @@ func (s *SimpleDB) Print(f Formula) {
     s.writer.Print(fun(&f, 0))
 }
 
+// labels calldata and its slices with function and argument names from abi
+func (s *SimpleDB) calldataLabel(f *Formula) string {
+    abi := s.logger.toLog.abi
+    switch f.opcode {
+    case OPCallData:
+        if entry, ok := abi.Function(f.result); ok {
+            return " # " + entry.Signature()
+        }
+    case OPSlice:
+        calldata := s.GetFormula(f.operands[0])
+        if calldata.opcode != OPCallData {
+            return ""
+        }
+        _, ranges, ok := abi.CalldataRanges(calldata.result)
+        if !ok {
+            return ""
+        }
+        offset := binary.BigEndian.Uint64(s.GetFormula(f.operands[1]).result)
+        size := binary.BigEndian.Uint64(s.GetFormula(f.operands[2]).result)
+        for _, r := range ranges {
+            if offset >= r.Offset && offset + size <= r.Offset + r.Size {
+                return " " + r.Name + " (" + r.Type + ")"
+            }
+        }
+    }
+    return ""
+}
+
 func (s *SimpleDB) FullPrint(f Formula) {
     s.Print(f)
     for _, short := range s.shorts {
@@ -0,0 +1,344 @@
+package dep_tracer
+
+import (
+    "os"
+    "fmt"
+    "strconv"
+    "strings"
+    "math/big"
+    "encoding/hex"
+    "encoding/json"
+)
+
+type StorageLayoutVariable struct {
+    Label  string `json:"label"`
+    Offset uint64 `json:"offset"`
+    Slot   string `json:"slot"`
+    Type   string `json:"type"`
+}
+
+type StorageLayoutType struct {
+    Encoding      string                  `json:"encoding"`
+    Label         string                  `json:"label"`
+    NumberOfBytes string                  `json:"numberOfBytes"`
+    Key           string                  `json:"key"`
+    Value         string                  `json:"value"`
+    Base          string                  `json:"base"`
+    Members       []StorageLayoutVariable `json:"members"`
+}
+
+type StorageLayout struct {
+    Storage []StorageLayoutVariable      `json:"storage"`
+    Types   map[string]StorageLayoutType `json:"types"`
+}
+
+// accepts both plain storage layout and compiler artifacts with "storageLayout" field
+func LoadStorageLayout(path string) *StorageLayout {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        panic(err)
+    }
+    var file struct {
+        StorageLayout
+        Artifact *StorageLayout `json:"storageLayout"`
+    }
+    if err := json.Unmarshal(data, &file); err != nil {
+        panic(fmt.Errorf("failed to parse storage layout %s: %v", path, err))
+    }
+    if file.Artifact != nil {
+        return file.Artifact
+    }
+    return &file.StorageLayout
+}
+
+type SolLayoutField struct {
+    Name   string `json:"name"`
+    Type   string `json:"type"`
+    Offset uint64 `json:"offset"`
+    Size   uint64 `json:"size"`
+    Value  string `json:"value"`
+}
+
+type SolLayoutView struct {
+    Path   string           `json:"path"`
+    Fields []SolLayoutField `json:"fields"`
+}
+
+func (v *SolLayoutView) Print(writer OutputWriter) {
+    writer.Println("# path", v.Path)
+    for _, field := range v.Fields {
+        writer.Println("# field", field.Name, "("+field.Type+")", field.Value)
+    }
+}
+
+const (
+    // virtual encodings for data areas which are addressed by keccak of slot
+    layoutArrayData = "array_data"
+    layoutBytesData = "bytes_data"
+)
+
+// position inside of storage, rel is slot offset from the beginning of typ
+type layoutCursor struct {
+    typ  StorageLayoutType
+    path string
+    rel  uint64
+}
+
+func (l *StorageLayout) root() StorageLayoutType {
+    return StorageLayoutType{Encoding: "inplace", Members: l.Storage, NumberOfBytes: "0"}
+}
+
+func (l *StorageLayout) typeSize(name string) uint64 {
+    t, ok := l.Types[name]
+    if !ok {
+        return 32
+    }
+    size, err := strconv.ParseUint(t.NumberOfBytes, 10, 64)
+    if err != nil {
+        return 32
+    }
+    return size
+}
+
+func (l *StorageLayout) typeSlots(name string) uint64 {
+    size := l.typeSize(name)
+    if size == 0 {
+        return 1
+    }
+    return (size + 31) / 32
+}
+
+func (l *StorageLayout) typeLabel(name string) string {
+    if t, ok := l.Types[name]; ok && t.Label != "" {
+        return t.Label
+    }
+    return name
+}
+
+func layoutVariableSlot(v StorageLayoutVariable) (uint64, bool) {
+    slot, err := strconv.ParseUint(v.Slot, 10, 64)
+    return slot, err == nil
+}
+
+// elements of array which are packed into one slot, 1 if element takes whole slots
+func (l *StorageLayout) elementsPerSlot(base string) uint64 {
+    size := l.typeSize(base)
+    if size == 0 || size > 16 {
+        return 1
+    }
+    return 32 / size
+}
+
+// moves cursor into the innermost type that starts at rel, returns fields if
+// slot is shared by several packed values
+func (l *StorageLayout) descend(c layoutCursor) (layoutCursor, []SolLayoutField, bool) {
+    for {
+        switch {
+        case c.typ.Encoding == "inplace" && c.typ.Members != nil:
+            candidates := []StorageLayoutVariable{}
+            for _, member := range c.typ.Members {
+                slot, ok := layoutVariableSlot(member)
+                if !ok {
+                    return c, nil, false
+                }
+                if slot <= c.rel && c.rel < slot + l.typeSlots(member.Type) {
+                    candidates = append(candidates, member)
+                }
+            }
+            if len(candidates) == 0 {
+                return c, nil, false
+            }
+            if len(candidates) > 1 {
+                fields := []SolLayoutField{}
+                for _, member := range candidates {
+                    fields = append(fields, SolLayoutField{
+                        Name:   l.joinPath(c.path, member.Label),
+                        Type:   l.typeLabel(member.Type),
+                        Offset: member.Offset,
+                        Size:   l.typeSize(member.Type),
+                    })
+                }
+                return c, fields, true
+            }
+            member := candidates[0]
+            slot, _ := layoutVariableSlot(member)
+            if member.Offset != 0 {
+                field := SolLayoutField{
+                    Name:   l.joinPath(c.path, member.Label),
+                    Type:   l.typeLabel(member.Type),
+                    Offset: member.Offset,
+                    Size:   l.typeSize(member.Type),
+                }
+                return c, []SolLayoutField{field}, true
+            }
+            c = layoutCursor{l.Types[member.Type], l.joinPath(c.path, member.Label), c.rel - slot}
+            if _, ok := l.Types[member.Type]; !ok {
+                c.typ = StorageLayoutType{Encoding: "inplace", Label: member.Type, NumberOfBytes: "32"}
+            }
+        case c.typ.Encoding == "inplace" && c.typ.Base != "" || c.typ.Encoding == layoutArrayData:
+            perSlot := l.elementsPerSlot(c.typ.Base)
+            if perSlot > 1 {
+                fields := []SolLayoutField{}
+                size := l.typeSize(c.typ.Base)
+                for i := uint64(0); i < perSlot; i++ {
+                    fields = append(fields, SolLayoutField{
+                        Name:   c.path + "[" + strconv.FormatUint(c.rel * perSlot + i, 10) + "]",
+                        Type:   l.typeLabel(c.typ.Base),
+                        Offset: i * size,
+                        Size:   size,
+                    })
+                }
+                return c, fields, true
+            }
+            slots := l.typeSlots(c.typ.Base)
+            index := c.rel / slots
+            c = layoutCursor{l.Types[c.typ.Base], c.path + "[" + strconv.FormatUint(index, 10) + "]", c.rel % slots}
+        case c.typ.Encoding == layoutBytesData:
+            field := SolLayoutField{
+                Name:   c.path + " data chunk " + strconv.FormatUint(c.rel, 10),
+                Type:   c.typ.Label,
+                Offset: 0,
+                Size:   32,
+            }
+            return c, []SolLayoutField{field}, true
+        default:
+            if c.rel != 0 {
+                return c, nil, false
+            }
+            return c, nil, true
+        }
+    }
+}
+
+func (l *StorageLayout) joinPath(path, label string) string {
+    if path == "" {
+        return label
+    }
+    return path + "." + label
+}
+
+// resolves solidity view of slot into variable path, value is stored slot value
+func (l *StorageLayout) Resolve(view SolView, value []byte) (*SolLayoutView, bool) {
+    if len(view) == 0 || view[0].Type != 'c' {
+        return nil, false
+    }
+    slot := new(big.Int).SetBytes(view[0].Data)
+    if !slot.IsUint64() {
+        return nil, false
+    }
+    c := layoutCursor{l.root(), "", slot.Uint64()}
+
+    for _, line := range view[1:] {
+        switch line.Type {
+        case 'o':
+            offset := new(big.Int).SetBytes(line.Data)
+            if !offset.IsUint64() {
+                return nil, false
+            }
+            c.rel += offset.Uint64()
+        case 'm':
+            var fields []SolLayoutField
+            var ok bool
+            c, fields, ok = l.descend(c)
+            if !ok || fields != nil || c.typ.Encoding != "mapping" {
+                return nil, false
+            }
+            key := l.formatValue(line.Data, c.typ.Key, true)
+            c = layoutCursor{l.Types[c.typ.Value], c.path + "[" + key + "]", 0}
+        case 'd', 'a', 's':
+            var fields []SolLayoutField
+            var ok bool
+            c, fields, ok = l.descend(c)
+            if !ok || fields != nil {
+                return nil, false
+            }
+            switch c.typ.Encoding {
+            case "dynamic_array":
+                c = layoutCursor{StorageLayoutType{Encoding: layoutArrayData, Base: c.typ.Base}, c.path, 0}
+            case "bytes":
+                c = layoutCursor{StorageLayoutType{Encoding: layoutBytesData, Label: c.typ.Label}, c.path, 0}
+            default:
+                return nil, false
+            }
+            if line.Type != 'd' {
+                offset := new(big.Int).SetBytes(line.Data)
+                if !offset.IsUint64() {
+                    return nil, false
+                }
+                c.rel = offset.Uint64()
+            }
+        default:
+            return nil, false
+        }
+    }
+
+    c, fields, ok := l.descend(c)
+    if !ok {
+        return nil, false
+    }
+    if fields == nil {
+        size, err := strconv.ParseUint(c.typ.NumberOfBytes, 10, 64)
+        if err != nil || size > 32 || c.typ.Encoding != "inplace" {
+            size = 32
+        }
+        fields = []SolLayoutField{{Name: c.path, Type: c.typ.Label, Offset: 0, Size: size}}
+    }
+    for i := range fields {
+        fields[i].Value = l.formatField(value, fields[i])
+    }
+    return &SolLayoutView{c.path, fields}, true
+}
+
+func (l *StorageLayout) formatField(value []byte, field SolLayoutField) string {
+    if len(value) != 32 || field.Offset + field.Size > 32 {
+        return "0x" + hex.EncodeToString(value)
+    }
+    data := value[32-field.Offset-field.Size:32-field.Offset]
+    return l.formatLabel(data, field.Type, false)
+}
+
+func (l *StorageLayout) formatValue(data []byte, typ string, isKey bool) string {
+    return l.formatLabel(data, l.typeLabel(typ), isKey)
+}
+
+// keys of mappings are padded to 32 bytes (except of dynamic ones), stored values are not
+func (l *StorageLayout) formatLabel(data []byte, label string, isKey bool) string {
+    switch {
+    case label == "string":
+        return strconv.Quote(string(data))
+    case label == "bool":
+        return strconv.FormatBool(new(big.Int).SetBytes(data).Sign() != 0)
+    case label == "address" || label == "address payable" || strings.HasPrefix(label, "contract "):
+        if len(data) > 20 {
+            data = data[len(data)-20:]
+        }
+        return "0x" + hex.EncodeToString(data)
+    case strings.HasPrefix(label, "uint") || strings.HasPrefix(label, "enum "):
+        return new(big.Int).SetBytes(data).String()
+    case strings.HasPrefix(label, "int"):
+        bits, err := strconv.Atoi(label[len("int"):])
+        if err != nil {
+            bits = 256
+        }
+        val := new(big.Int).SetBytes(data)
+        if isKey || bits > 256 {
+            bits = 256
+        }
+        // malformed label (like int0) must not make sign bit negative
+        if bits < 8 {
+            bits = 8
+        }
+        if val.Bit(bits-1) == 1 {
+            val.Sub(val, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+        }
+        return val.String()
+    case strings.HasPrefix(label, "bytes") && label != "bytes":
+        size, err := strconv.Atoi(label[len("bytes"):])
+        if err == nil && isKey && size <= len(data) {
+            data = data[:size]
+        }
+        return "0x" + hex.EncodeToString(data)
+    default:
+        return "0x" + hex.EncodeToString(data)
+    }
+}
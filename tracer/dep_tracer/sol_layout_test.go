@@ -0,0 +1,212 @@
+package dep_tracer
+
+import (
+    "os"
+    "testing"
+    "strings"
+    "encoding/hex"
+    "path/filepath"
+)
+
+// contract C {
+//     uint128 a; int64 b; bool c;
+//     struct User { uint256 balance; bool flag; uint8 tag; }
+//     mapping(address => User) users;
+//     struct Item { uint256 x; uint256 y; }
+//     Item[] items;
+//     string name;
+//     uint16[] small;
+// }
+const solLayoutTestFixture = `{"storageLayout": {
+    "storage": [
+        {"label": "a", "offset": 0, "slot": "0", "type": "t_uint128"},
+        {"label": "b", "offset": 16, "slot": "0", "type": "t_int64"},
+        {"label": "c", "offset": 24, "slot": "0", "type": "t_bool"},
+        {"label": "users", "offset": 0, "slot": "1", "type": "t_mapping(t_address,t_struct(User)1_storage)"},
+        {"label": "items", "offset": 0, "slot": "2", "type": "t_array(t_struct(Item)2_storage)dyn_storage"},
+        {"label": "name", "offset": 0, "slot": "3", "type": "t_string_storage"},
+        {"label": "small", "offset": 0, "slot": "4", "type": "t_array(t_uint16)dyn_storage"}
+    ],
+    "types": {
+        "t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+        "t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+        "t_int64": {"encoding": "inplace", "label": "int64", "numberOfBytes": "8"},
+        "t_uint8": {"encoding": "inplace", "label": "uint8", "numberOfBytes": "1"},
+        "t_uint16": {"encoding": "inplace", "label": "uint16", "numberOfBytes": "2"},
+        "t_uint128": {"encoding": "inplace", "label": "uint128", "numberOfBytes": "16"},
+        "t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+        "t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+        "t_mapping(t_address,t_struct(User)1_storage)": {
+            "encoding": "mapping", "label": "mapping(address => struct C.User)", "numberOfBytes": "32",
+            "key": "t_address", "value": "t_struct(User)1_storage"
+        },
+        "t_struct(User)1_storage": {
+            "encoding": "inplace", "label": "struct C.User", "numberOfBytes": "64",
+            "members": [
+                {"label": "balance", "offset": 0, "slot": "0", "type": "t_uint256"},
+                {"label": "flag", "offset": 0, "slot": "1", "type": "t_bool"},
+                {"label": "tag", "offset": 1, "slot": "1", "type": "t_uint8"}
+            ]
+        },
+        "t_array(t_struct(Item)2_storage)dyn_storage": {
+            "encoding": "dynamic_array", "label": "struct C.Item[]", "numberOfBytes": "32",
+            "base": "t_struct(Item)2_storage"
+        },
+        "t_struct(Item)2_storage": {
+            "encoding": "inplace", "label": "struct C.Item", "numberOfBytes": "64",
+            "members": [
+                {"label": "x", "offset": 0, "slot": "0", "type": "t_uint256"},
+                {"label": "y", "offset": 0, "slot": "1", "type": "t_uint256"}
+            ]
+        },
+        "t_array(t_uint16)dyn_storage": {
+            "encoding": "dynamic_array", "label": "uint16[]", "numberOfBytes": "32",
+            "base": "t_uint16"
+        }
+    }
+}}`
+
+func solLayoutTestLoad(t *testing.T) *StorageLayout {
+    path := filepath.Join(t.TempDir(), "layout.json")
+    if err := os.WriteFile(path, []byte(solLayoutTestFixture), 0644); err != nil {
+        t.Fatal(err)
+    }
+    return LoadStorageLayout(path)
+}
+
+// word with bytes placed at offsets counted from the end, as packed values are stored
+func solLayoutTestValue(parts map[int][]byte) []byte {
+    res := make([]byte, 32)
+    for offset, data := range parts {
+        copy(res[32-offset-len(data):], data)
+    }
+    return res
+}
+
+func TestSolLayoutResolve(t *testing.T) {
+    l := solLayoutTestLoad(t)
+
+    addrKey := make([]byte, 32)
+    copy(addrKey[12:], strings.Repeat("\xab", 20))
+    addr := "0x" + strings.Repeat("ab", 20)
+    chunk := []byte("layout of long string, 2nd chunk")
+
+    tests := []struct {
+        name   string
+        view   SolView
+        value  []byte
+        ok     bool
+        path   string
+        count  int
+        // name of field -> formatted value
+        fields map[string]string
+    }{
+        {
+            "packed slot",
+            SolView{{Type: 'c', Data: solViewTestWord(0)}},
+            solLayoutTestValue(map[int][]byte{0: {5}, 16: {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, 24: {1}}),
+            true, "", 3,
+            map[string]string{"a": "5", "b": "-2", "c": "true"},
+        },
+        {
+            "struct member in mapping",
+            SolView{{Type: 'c', Data: solViewTestWord(1)}, {Type: 'm', Data: addrKey}},
+            solViewTestWord(100),
+            true, "users[" + addr + "].balance", 1,
+            map[string]string{"users[" + addr + "].balance": "100"},
+        },
+        {
+            "packed struct members in mapping",
+            SolView{{Type: 'c', Data: solViewTestWord(1)}, {Type: 'm', Data: addrKey}, {Type: 'o', Data: solViewTestWord(1)}},
+            solLayoutTestValue(map[int][]byte{0: {1}, 1: {7}}),
+            true, "users[" + addr + "]", 2,
+            map[string]string{"users[" + addr + "].flag": "true", "users[" + addr + "].tag": "7"},
+        },
+        {
+            "member of struct in dynamic array",
+            SolView{{Type: 'c', Data: solViewTestWord(2)}, {Type: 'a', Data: solViewTestWord(6)}, {Type: 'o', Data: solViewTestWord(1)}},
+            solViewTestWord(42),
+            true, "items[3].y", 1,
+            map[string]string{"items[3].y": "42"},
+        },
+        {
+            "bytes chunk",
+            SolView{{Type: 'c', Data: solViewTestWord(3)}, {Type: 's', Data: solViewTestWord(2)}},
+            chunk,
+            true, "name", 1,
+            map[string]string{"name data chunk 2": `"` + string(chunk) + `"`},
+        },
+        {
+            "packed dynamic array",
+            SolView{{Type: 'c', Data: solViewTestWord(4)}, {Type: 'a', Data: solViewTestWord(1)}},
+            solLayoutTestValue(map[int][]byte{0: {0, 3}, 30: {0x12, 0x34}}),
+            true, "small", 16,
+            map[string]string{"small[16]": "3", "small[17]": "0", "small[31]": "4660"},
+        },
+        {
+            "unknown slot",
+            SolView{{Type: 'c', Data: solViewTestWord(9)}},
+            solViewTestWord(0),
+            false, "", 0, nil,
+        },
+        {
+            "mapping key on plain value",
+            SolView{{Type: 'c', Data: solViewTestWord(3)}, {Type: 'm', Data: addrKey}},
+            solViewTestWord(0),
+            false, "", 0, nil,
+        },
+    }
+    for _, test := range tests {
+        view, ok := l.Resolve(test.view, test.value)
+        if ok != test.ok {
+            t.Errorf("%s: resolved is %v, expected %v", test.name, ok, test.ok)
+            continue
+        }
+        if !ok {
+            continue
+        }
+        if view.Path != test.path {
+            t.Errorf("%s: path is %q, expected %q", test.name, view.Path, test.path)
+        }
+        if len(view.Fields) != test.count {
+            t.Errorf("%s: %d fields, expected %d", test.name, len(view.Fields), test.count)
+        }
+        found := 0
+        for _, field := range view.Fields {
+            if expected, ok := test.fields[field.Name]; ok {
+                found++
+                if field.Value != expected {
+                    t.Errorf("%s: field %s is %s, expected %s", test.name, field.Name, field.Value, expected)
+                }
+            }
+        }
+        if found != len(test.fields) {
+            t.Errorf("%s: fields are %v, expected %v", test.name, view.Fields, test.fields)
+        }
+    }
+}
+
+func TestSolLayoutFormatInt(t *testing.T) {
+    l := solLayoutTestLoad(t)
+    tests := []struct {
+        data  string
+        label string
+        isKey bool
+        value string
+    }{
+        {"ff", "int8", false, "-1"},
+        {"7f", "int8", false, "127"},
+        {"00000000000000ff", "int64", true, "255"},
+        {"ff", "int", false, "255"},
+        {"ff", "int300", false, "255"},
+        // malformed labels
+        {"ff", "int0", false, "-1"},
+        {"ff", "int-8", false, "-1"},
+    }
+    for _, test := range tests {
+        data, _ := hex.DecodeString(test.data)
+        if value := l.formatLabel(data, test.label, test.isKey); value != test.value {
+            t.Errorf("%s %s: formatted as %s, expected %s", test.label, test.data, value, test.value)
+        }
+    }
+}
@@ -5,8 +5,11 @@ import (
 )
 
 type solViewLine struct {
-    Type uint8
-    Data []byte
+    Type  uint8
+    Data  []byte
+    // only for arrays
+    Index []byte
+    Size  []byte
 }
 
 type SolView []solViewLine
@@ -19,11 +22,13 @@ func (s *SolView) Print(writer OutputWriter) {
         case 'o':
             writer.Println("#", i, "offset  ", hex.EncodeToString(line.Data))
         case 'm':
-            writer.Print("# ", i, " mapping  ", hex.EncodeToString(line.Data))
-            if len(line.Data) == 0 {
-                writer.Print("(possibly array)")
-            }
-            writer.Println()
+            writer.Println("#", i, "mapping ", hex.EncodeToString(line.Data))
+        case 'a':
+            writer.Println("#", i, "array   ", hex.EncodeToString(line.Index), "*", hex.EncodeToString(line.Size))
+        case 'd':
+            writer.Println("#", i, "data     (array or long string/bytes)")
+        case 's':
+            writer.Println("#", i, "string  ", hex.EncodeToString(line.Data))
         default:
             panic("unknown type")
         }
@@ -40,6 +45,12 @@ func (s *SolView) JSON() [][2]string {
             res = append(res, [2]string{"offset", hex.EncodeToString(line.Data)})
         case 'm':
             res = append(res, [2]string{"mapping", hex.EncodeToString(line.Data)})
+        case 'a':
+            res = append(res, [2]string{"array", hex.EncodeToString(line.Index) + "*" + hex.EncodeToString(line.Size)})
+        case 'd':
+            res = append(res, [2]string{"data", ""})
+        case 's':
+            res = append(res, [2]string{"string", hex.EncodeToString(line.Data)})
         default:
             panic("unknown type")
         }
@@ -47,61 +58,225 @@ func (s *SolView) JSON() [][2]string {
     return res
 }
 
-func SolViewNew(s *SimpleDB, formula Formula) SolView {
-    allZero := func(s []byte) bool {
-        for _, v := range s {
-            if v != 0 {
-                return false
+func solViewAllZero(s []byte) bool {
+    for _, v := range s {
+        if v != 0 {
+            return false
+        }
+    }
+    return true
+}
+
+func solViewIsOne(s []byte) bool {
+    return len(s) > 0 && s[len(s)-1] == 1 && solViewAllZero(s[:len(s)-1])
+}
+
+// slot is derived from keccak, possibly with offsets added
+func solViewIsSlot(s *SimpleDB, formula Formula) bool {
+    switch formula.opcode {
+    case OPKeccak:
+        return true
+    case OPAdd:
+        return solViewIsSlot(s, s.GetFormula(formula.operands[0])) || solViewIsSlot(s, s.GetFormula(formula.operands[1]))
+    default:
+        return false
+    }
+}
+
+// unrolls chain of additions to keccak, offsets are ordered from keccak outwards
+func solViewAddChain(s *SimpleDB, formula Formula) (Formula, []Formula) {
+    offsets := []Formula{}
+    for formula.opcode == OPAdd {
+        op0 := s.GetFormula(formula.operands[0])
+        op1 := s.GetFormula(formula.operands[1])
+        if !solViewIsSlot(s, op0) {
+            op0, op1 = op1, op0
+        }
+        if !solViewIsSlot(s, op0) || solViewIsSlot(s, op1) {
+            break
+        }
+        if !solViewAllZero(op1.result) {
+            offsets = append([]Formula{op1}, offsets...)
+        }
+        formula = op0
+    }
+    return formula, offsets
+}
+
+// keccak with empty key is beginning of dynamic array or long string/bytes data
+func solViewData(s *SimpleDB, offsets []Formula) (SolView, []Formula) {
+    if len(offsets) == 0 {
+        return SolView{solViewLine{Type: 'd'}}, offsets
+    }
+    if len(offsets) > 1 {
+        // copy loops of long strings move by one slot
+        allOnes := true
+        for _, offset := range offsets {
+            if offset.opcode != OPConstant || !solViewIsOne(offset.result) {
+                allOnes = false
+                break
             }
         }
+        if allOnes {
+            chunk := make([]byte, 32)
+            chunk[31] = byte(len(offsets))
+            return SolView{solViewLine{Type: 's', Data: chunk}}, nil
+        }
+    }
+    return SolView{solViewIndex(s, offsets[0])}, offsets[1:]
+}
+
+const (
+    SolViewSolidity = "solidity"
+    SolViewVyper    = "vyper"
+    SolViewAuto     = "auto"
+)
+
+// whole value is kept as is, so that keccak of keccak (nested mappings and arrays) is still seen
+func solViewSlice(s *SimpleDB, formula Formula, offset, size uint64) Formula {
+    if offset == 0 && size == uint64(len(formula.result)) {
+        return formula
+    }
+    return s.FormulaSlice(formula, offset, size)
+}
+
+// slices of constants are constants too
+func solViewIsConstant(s *SimpleDB, formula Formula) bool {
+    switch formula.opcode {
+    case OPConstant:
+        return true
+    case OPSlice:
+        return s.GetFormula(formula.operands[0]).opcode == OPConstant
+    default:
+        return false
+    }
+}
+
+// slot-like values are small constants or derived from keccak
+func solViewIsSlotLike(s *SimpleDB, formula Formula) bool {
+    if solViewIsSlot(s, formula) {
         return true
     }
+    if !solViewIsConstant(s, formula) || len(formula.result) < 8 {
+        return false
+    }
+    return solViewAllZero(formula.result[:len(formula.result)-8])
+}
 
+// address-sized constants are too large for slots and too small for hashes, so they are
+// unambiguous keys
+func solViewIsKey(s *SimpleDB, formula Formula) bool {
+    if !solViewIsConstant(s, formula) || len(formula.result) < 20 || solViewIsSlotLike(s, formula) {
+        return false
+    }
+    return solViewAllZero(formula.result[:len(formula.result)-20])
+}
+
+// solidity hashes key . slot, vyper hashes slot . key (keys are always 32 bytes),
+// auto decides only if one part looks like a slot and the other one like a key,
+// otherwise (for example small integer keys or hashed keys) fallback compiler is used
+func solViewIsVyper(s *SimpleDB, keccakValueFormula Formula, compiler string, fallback string) bool {
+    if len(keccakValueFormula.result) != 64 {
+        return false
+    }
+    switch compiler {
+    case SolViewVyper:
+        return true
+    case SolViewAuto:
+        first := solViewSlice(s, keccakValueFormula, 0, 32)
+        last := solViewSlice(s, keccakValueFormula, 32, 32)
+        if solViewIsSlotLike(s, first) && solViewIsKey(s, last) {
+            return true
+        }
+        if solViewIsSlotLike(s, last) && solViewIsKey(s, first) {
+            return false
+        }
+        return fallback == SolViewVyper
+    default:
+        return false
+    }
+}
+
+// index * element size, element of one slot otherwise
+func solViewIndex(s *SimpleDB, formula Formula) solViewLine {
+    if formula.opcode == OPMul {
+        op0 := s.GetFormula(formula.operands[0])
+        op1 := s.GetFormula(formula.operands[1])
+        if op0.opcode == OPConstant {
+            op0, op1 = op1, op0
+        }
+        if op1.opcode == OPConstant {
+            return solViewLine{Type: 'a', Data: formula.result, Index: op0.result, Size: op1.result}
+        }
+    }
+    one := make([]byte, 32)
+    one[31] = 1
+    return solViewLine{Type: 'a', Data: formula.result, Index: formula.result, Size: one}
+}
+
+// vyper stores dynamic arrays after their length slot (slot + 1 + index * size),
+// arrays outside of mappings are not hashed, so they are seen as constants
+func solViewVyperArray(s *SimpleDB, offsets []Formula) (SolView, []Formula) {
+    if len(offsets) < 2 || offsets[0].opcode != OPConstant || !solViewIsOne(offsets[0].result) {
+        return SolView{}, offsets
+    }
+    // length slot
+    return SolView{solViewLine{Type: 'o', Data: offsets[0].result}, solViewIndex(s, offsets[1])}, offsets[2:]
+}
+
+func SolViewNew(s *SimpleDB, formula Formula, compiler string, fallback string) SolView {
     res := SolView{}
     switch (formula.opcode) {
-    case OPKeccak:
-        keccakValueFormula := s.GetFormula(formula.operands[0])
-        l := len(keccakValueFormula.result)
-        if l >= 32 {
-            slotFormula := s.FormulaSlice(keccakValueFormula, uint64(l-32), 32)
-            res = append(res, SolViewNew(s, slotFormula)...)
-
-            val := s.FormulaSlice(keccakValueFormula, 0, uint64(l-32)).result
-            // mapping (or array when key length is zero)
-            res = append(res, solViewLine{'m', val})
-        } else {
+    case OPKeccak, OPAdd:
+        keccakFormula, offsets := solViewAddChain(s, formula)
+        if keccakFormula.opcode != OPKeccak {
             val := formula.result
             // constant
-            res = append(res, solViewLine{'c', val})
+            res = append(res, solViewLine{Type: 'c', Data: val})
+            break
         }
-    case OPAdd:
-        op0 := s.GetFormula(formula.operands[0])
-        op1 := s.GetFormula(formula.operands[1])
-        if op0.opcode != OPKeccak && op1.opcode != OPKeccak {
+        keccakValueFormula := s.GetFormula(keccakFormula.operands[0])
+        l := len(keccakValueFormula.result)
+        if l < 32 {
             val := formula.result
             // constant
-            res = append(res, solViewLine{'c', val})
+            res = append(res, solViewLine{Type: 'c', Data: val})
+            break
+        }
+        if solViewIsVyper(s, keccakValueFormula, compiler, fallback) {
+            slotFormula := solViewSlice(s, keccakValueFormula, 0, 32)
+            res = append(res, SolViewNew(s, slotFormula, compiler, fallback)...)
+
+            val := solViewSlice(s, keccakValueFormula, 32, 32).result
+            // vyper mapping
+            res = append(res, solViewLine{Type: 'm', Data: val})
+
+            var array SolView
+            array, offsets = solViewVyperArray(s, offsets)
+            res = append(res, array...)
+        } else if l == 32 {
+            slotFormula := solViewSlice(s, keccakValueFormula, 0, 32)
+            res = append(res, SolViewNew(s, slotFormula, compiler, fallback)...)
+
+            var data SolView
+            data, offsets = solViewData(s, offsets)
+            res = append(res, data...)
         } else {
-            if op0.opcode != OPKeccak {
-                op0, op1 = op1, op0
-            }
-            slotFormula := op0
-            res = append(res, SolViewNew(s, slotFormula)...)
+            slotFormula := solViewSlice(s, keccakValueFormula, uint64(l-32), 32)
+            res = append(res, SolViewNew(s, slotFormula, compiler, fallback)...)
 
-            val := op1.result
-            if !allZero(val) {
-                // offset
-                res = append(res, solViewLine{'o', val})
-            }
+            val := solViewSlice(s, keccakValueFormula, 0, uint64(l-32)).result
+            // mapping
+            res = append(res, solViewLine{Type: 'm', Data: val})
+        }
+        for _, offset := range offsets {
+            // offset
+            res = append(res, solViewLine{Type: 'o', Data: offset.result})
         }
-    case OPConstant:
-        val := formula.result
-        // constant
-        res = append(res, solViewLine{'c', val})
     default:
         val := formula.result
         // constant
-        res = append(res, solViewLine{'c', val})
+        res = append(res, solViewLine{Type: 'c', Data: val})
     }
     return res
 }
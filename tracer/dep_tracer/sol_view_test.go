@@ -0,0 +1,182 @@
+package dep_tracer
+
+import (
+    "testing"
+    "strings"
+    "encoding/hex"
+    "github.com/holiman/uint256"
+)
+
+type solViewTestWriter struct {
+    out []byte
+}
+
+func (w *solViewTestWriter) Write(data []byte) {
+    w.out = append(w.out, data...)
+}
+
+type solViewTestBuilder struct {
+    s *SimpleDB
+}
+
+func solViewTestWord(n uint64) []byte {
+    res := uint256.NewInt(n).Bytes32()
+    return res[:]
+}
+
+func solViewTestHex(n uint64) string {
+    return hex.EncodeToString(solViewTestWord(n))
+}
+
+func (b solViewTestBuilder) constant(val []byte) Formula {
+    return b.s.ConstantNew(OPConstant, val)
+}
+
+func (b solViewTestBuilder) calldata(val []byte) Formula {
+    return b.s.FormulaNew(OPCallData, val, []Hash{})
+}
+
+func (b solViewTestBuilder) concat(f0, f1 Formula) Formula {
+    res := append(append([]byte{}, f0.result...), f1.result...)
+    return b.s.FormulaNew(OPConcat, res, []Hash{f0.hash, f1.hash})
+}
+
+func (b solViewTestBuilder) keccak(f Formula) Formula {
+    return b.s.FormulaNew(OPKeccak, Keccak256(f.result), []Hash{f.hash})
+}
+
+func (b solViewTestBuilder) add(f0, f1 Formula) Formula {
+    res := new(uint256.Int).Add(new(uint256.Int).SetBytes(f0.result), new(uint256.Int).SetBytes(f1.result)).Bytes32()
+    return b.s.FormulaNew(OPAdd, res[:], []Hash{f0.hash, f1.hash})
+}
+
+func (b solViewTestBuilder) mul(f0, f1 Formula) Formula {
+    res := new(uint256.Int).Mul(new(uint256.Int).SetBytes(f0.result), new(uint256.Int).SetBytes(f1.result)).Bytes32()
+    return b.s.FormulaNew(OPMul, res[:], []Hash{f0.hash, f1.hash})
+}
+
+func TestSolView(t *testing.T) {
+    w := new(solViewTestWriter)
+    h := NewDepHandler([]byte(`{"kv":{"engine":"memory"},"logger":{}}`), w)
+    b := solViewTestBuilder{h.db}
+
+    addrKey := make([]byte, 32)
+    copy(addrKey[12:], strings.Repeat("\xab", 20))
+    addrKeyHex := hex.EncodeToString(addrKey)
+    key := b.constant(addrKey)
+    smallKey := b.constant(solViewTestWord(7))
+    slot := func(n uint64) Formula {
+        return b.constant(solViewTestWord(n))
+    }
+    one := b.constant(solViewTestWord(1))
+    index := b.calldata(solViewTestWord(5))
+    elemSize := b.constant(solViewTestWord(2))
+
+    // mapping(uint => uint[]) at slot 1, element of index 5
+    nestedArray := b.add(b.keccak(b.keccak(b.concat(smallKey, slot(1)))), index)
+    // vyper HashMap[address, DynArray[uint256, 10]] at slot 4, element of index 5 of size 2
+    vyperArray := b.add(b.add(b.keccak(b.concat(slot(4), key)), one), b.mul(index, elemSize))
+
+    tests := []struct {
+        name     string
+        formula  Formula
+        compiler string
+        fallback string
+        json     [][2]string
+        print    []string
+    }{
+        {
+            "plain slot", slot(3), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(3)}},
+            []string{"# 0 constant " + solViewTestHex(3)},
+        },
+        {
+            "solidity mapping", b.keccak(b.concat(key, slot(3))), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(3)}, {"mapping", addrKeyHex}},
+            []string{"# 0 constant " + solViewTestHex(3), "# 1 mapping  " + addrKeyHex},
+        },
+        {
+            "solidity mapping to struct member", b.add(b.keccak(b.concat(key, slot(3))), slot(2)), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(3)}, {"mapping", addrKeyHex}, {"offset", solViewTestHex(2)}},
+            []string{"# 0 constant " + solViewTestHex(3), "# 1 mapping  " + addrKeyHex, "# 2 offset   " + solViewTestHex(2)},
+        },
+        {
+            "dynamic array data", b.keccak(slot(2)), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(2)}, {"data", ""}},
+            []string{"# 0 constant " + solViewTestHex(2), "# 1 data     (array or long string/bytes)"},
+        },
+        {
+            "dynamic array element", b.add(b.keccak(slot(2)), b.mul(index, elemSize)), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(2)}, {"array", solViewTestHex(5) + "*" + solViewTestHex(2)}},
+            []string{"# 0 constant " + solViewTestHex(2), "# 1 array    " + solViewTestHex(5) + " * " + solViewTestHex(2)},
+        },
+        {
+            // single +1 can not be told apart from element 1, so it is an element
+            "dynamic array element without multiplication", b.add(b.keccak(slot(2)), one), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(2)}, {"array", solViewTestHex(1) + "*" + solViewTestHex(1)}},
+            []string{"# 0 constant " + solViewTestHex(2), "# 1 array    " + solViewTestHex(1) + " * " + solViewTestHex(1)},
+        },
+        {
+            "long string chunk", b.add(b.add(b.keccak(slot(6)), one), one), SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(6)}, {"string", solViewTestHex(2)}},
+            []string{"# 0 constant " + solViewTestHex(6), "# 1 string   " + solViewTestHex(2)},
+        },
+        {
+            "nested mapping to array", nestedArray, SolViewSolidity, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(1)}, {"mapping", solViewTestHex(7)}, {"array", solViewTestHex(5) + "*" + solViewTestHex(1)}},
+            []string{"# 0 constant " + solViewTestHex(1), "# 1 mapping  " + solViewTestHex(7), "# 2 array    " + solViewTestHex(5) + " * " + solViewTestHex(1)},
+        },
+        {
+            "vyper mapping", b.keccak(b.concat(slot(4), key)), SolViewVyper, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(4)}, {"mapping", addrKeyHex}},
+            []string{"# 0 constant " + solViewTestHex(4), "# 1 mapping  " + addrKeyHex},
+        },
+        {
+            "vyper dynamic array in mapping", vyperArray, SolViewVyper, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(4)}, {"mapping", addrKeyHex}, {"offset", solViewTestHex(1)}, {"array", solViewTestHex(5) + "*" + solViewTestHex(2)}},
+            []string{"# 0 constant " + solViewTestHex(4), "# 1 mapping  " + addrKeyHex, "# 2 offset   " + solViewTestHex(1), "# 3 array    " + solViewTestHex(5) + " * " + solViewTestHex(2)},
+        },
+        {
+            "auto detects vyper order by address key", b.keccak(b.concat(slot(4), key)), SolViewAuto, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(4)}, {"mapping", addrKeyHex}},
+            []string{"# 0 constant " + solViewTestHex(4), "# 1 mapping  " + addrKeyHex},
+        },
+        {
+            "auto detects solidity order by address key", b.keccak(b.concat(key, slot(3))), SolViewAuto, SolViewVyper,
+            [][2]string{{"constant", solViewTestHex(3)}, {"mapping", addrKeyHex}},
+            []string{"# 0 constant " + solViewTestHex(3), "# 1 mapping  " + addrKeyHex},
+        },
+        {
+            // small key looks like a slot, so order is ambiguous
+            "auto falls back for small keys", b.keccak(b.concat(slot(4), smallKey)), SolViewAuto, SolViewVyper,
+            [][2]string{{"constant", solViewTestHex(4)}, {"mapping", solViewTestHex(7)}},
+            []string{"# 0 constant " + solViewTestHex(4), "# 1 mapping  " + solViewTestHex(7)},
+        },
+        {
+            "auto falls back to solidity", b.keccak(b.concat(slot(4), smallKey)), SolViewAuto, SolViewSolidity,
+            [][2]string{{"constant", solViewTestHex(7)}, {"mapping", solViewTestHex(4)}},
+            []string{"# 0 constant " + solViewTestHex(7), "# 1 mapping  " + solViewTestHex(4)},
+        },
+    }
+    for _, test := range tests {
+        view := SolViewNew(b.s, test.formula, test.compiler, test.fallback)
+
+        json := view.JSON()
+        if len(json) != len(test.json) {
+            t.Errorf("%s: json is %v, expected %v", test.name, json, test.json)
+        } else {
+            for i := range json {
+                if json[i] != test.json[i] {
+                    t.Errorf("%s: json line %d is %v, expected %v", test.name, i, json[i], test.json[i])
+                }
+            }
+        }
+
+        w.out = nil
+        view.Print(NewCallbackWriter(w))
+        expected := strings.Join(test.print, "\n") + "\n"
+        if string(w.out) != expected {
+            t.Errorf("%s: printed\n%s\nexpected\n%s", test.name, w.out, expected)
+        }
+    }
+}
@@ -47,6 +47,11 @@ func (st *Stack) Swap(n int) {
     st.Data[len(st.Data)-n], st.Data[len(st.Data)-1] = st.Data[len(st.Data)-1], st.Data[len(st.Data)-n]
 }
 
+// positions start from 1 at the top, as in EXCHANGE
+func (st *Stack) Exchange(n, m int) {
+    st.Data[len(st.Data)-n], st.Data[len(st.Data)-m] = st.Data[len(st.Data)-m], st.Data[len(st.Data)-n]
+}
+
 func (st *Stack) Dup(n int) {
     st.Push(st.Data[len(st.Data)-n])
 }
@@ -1,5 +1,9 @@
 package dep_tracer
 
+// guards are conditions of branches executed before in the frame (not control dependence,
+// branches which already rejoined are kept), so the list is capped
+const maxGuards = 64
+
 type StackedElement struct {
     isCreate     bool
     addr         Address
@@ -11,6 +15,24 @@ type StackedElement struct {
     initcodeHash Hash
     stack        *Stack
     memory       *Memory
+    // branch conditions (implicit flow) the frame execution depends on, inherited ones first,
+    // guardSites maps code position of branch to its guard
+    guards       []Formula
+    guardSites   map[uint64]int
+    codeFormulas map[Hash]bool
+    // value and gas passed by caller, nil if not tracked
+    callValue    []DEPByte
+    callGas      []DEPByte
+    // nil for legacy code, section is current code section, CALLF pushes return sections
+    eof            *EOFContainer
+    section        uint16
+    returnSections []uint16
+    // EIP-7702, code of delegate is executed in context of delegating account
+    delegated      bool
+    // opcode which entered the frame, 0 for transaction
+    callOp         byte
+    // dynamic jump targets of the frame, logged when the frame returns
+    jumpTargets    []Formula
 }
 
 func StackedElementNew(isCreate bool, addr Address, addrVersion uint64, codeAddr Address, calldata []DEPByte, code []DEPByte, codeHash, initcodeHash Hash) *StackedElement {
@@ -28,6 +50,52 @@ func StackedElementNew(isCreate bool, addr Address, addrVersion uint64, codeAddr
     return se
 }
 
+// condition of the same branch (loop iteration) replaces the previous one
+func (se *StackedElement) AddGuard(pos uint64, guard Formula) {
+    for _, g := range se.guards {
+        if g.hash == guard.hash {
+            return
+        }
+    }
+    if se.guardSites == nil {
+        se.guardSites = map[uint64]int{}
+    }
+    if i, ok := se.guardSites[pos]; ok {
+        se.guards[i] = guard
+        return
+    }
+    if len(se.guards) >= maxGuards {
+        return
+    }
+    se.guardSites[pos] = len(se.guards)
+    se.guards = append(se.guards, guard)
+}
+
+func (se *StackedElement) AddJumpTarget(target Formula) {
+    for _, jt := range se.jumpTargets {
+        if jt.hash == target.hash {
+            return
+        }
+    }
+    se.jumpTargets = append(se.jumpTargets, target)
+}
+
+// true if value consists only of code bytes (pushed constants) and zeroes
+func (se *StackedElement) IsCodeConstant(val []DEPByte) bool {
+    if se.codeFormulas == nil {
+        se.codeFormulas = map[Hash]bool{ConstantInitZero.hash: true}
+        for _, b := range se.code {
+            se.codeFormulas[b.formula] = true
+        }
+    }
+    for _, b := range val {
+        if !se.codeFormulas[b.formula] {
+            return false
+        }
+    }
+    return true
+}
+
 func (se *StackedElement) Copy() *StackedElement {
     res := new(StackedElement)
     res.isCreate = se.isCreate
@@ -39,6 +107,24 @@ func (se *StackedElement) Copy() *StackedElement {
     res.codeHash = se.codeHash
     res.stack = se.stack.Copy()
     res.memory = se.memory.Copy()
+    res.guards = make([]Formula, len(se.guards))
+    copy(res.guards, se.guards)
+    if se.guardSites != nil {
+        res.guardSites = make(map[uint64]int, len(se.guardSites))
+        for pos, i := range se.guardSites {
+            res.guardSites[pos] = i
+        }
+    }
+    res.callValue = se.callValue
+    res.callGas = se.callGas
+    res.eof = se.eof
+    res.section = se.section
+    res.returnSections = make([]uint16, len(se.returnSections))
+    copy(res.returnSections, se.returnSections)
+    res.delegated = se.delegated
+    res.callOp = se.callOp
+    res.jumpTargets = make([]Formula, len(se.jumpTargets))
+    copy(res.jumpTargets, se.jumpTargets)
     return res
 }
 
@@ -12,13 +12,45 @@ type Log struct {
     codeAddr    Address
     data        Formula
     topics      []Formula
+    guards      []Formula
 }
 
 
+type JumpTarget struct {
+    addr        Address
+    addrVersion uint64
+    codeAddr    Address
+    target      Formula
+}
+
+type ValueTransfer struct {
+    addr        Address
+    addrVersion uint64
+    codeAddr    Address
+    to          Address
+    amount      Formula
+}
+
+// read of pre-existing chain state, kind is balance, code, code_hash, slot or block_hash
+type WitnessRead struct {
+    addr  Address
+    kind  string
+    key   []byte
+    value Formula
+}
+
+type witnessKey struct {
+    addr Address
+    kind string
+    key  string
+}
+
 type TransactionState struct {
-    overlayDB *OverlayDB
-    stacked   *Stacked
-    logs      []Log
+    overlayDB      *OverlayDB
+    stacked        *Stacked
+    logs           []Log
+    jumpTargets    []JumpTarget
+    valueTransfers []ValueTransfer
 }
 
 func transactionStateNew(simpleDB *SimpleDB, isCreate bool, addr, codeAddr Address) *TransactionState {
@@ -27,6 +59,8 @@ func transactionStateNew(simpleDB *SimpleDB, isCreate bool, addr, codeAddr Addre
     addrVersion := ts.overlayDB.GetAddressVersion(addr)
     ts.stacked = StackedNew(isCreate, addr, addrVersion, codeAddr, []DEPByte{}, []DEPByte{}, Hash{}, Hash{})
     ts.logs = make([]Log, 0)
+    ts.jumpTargets = make([]JumpTarget, 0)
+    ts.valueTransfers = make([]ValueTransfer, 0)
     return ts
 }
 
@@ -36,10 +70,14 @@ func (ts *TransactionState) Copy() *TransactionState {
     res.stacked = ts.stacked.Copy()
     res.logs = make([]Log, len(ts.logs))
     copy(res.logs, ts.logs)
+    res.jumpTargets = make([]JumpTarget, len(ts.jumpTargets))
+    copy(res.jumpTargets, ts.jumpTargets)
+    res.valueTransfers = make([]ValueTransfer, len(ts.valueTransfers))
+    copy(res.valueTransfers, ts.valueTransfers)
     return res
 }
 
-func (ts *TransactionState) AddLog(addr Address, addrVersion uint64, codeAddr Address, data Formula, topics []Formula) {
+func (ts *TransactionState) AddLog(addr Address, addrVersion uint64, codeAddr Address, data Formula, topics []Formula, guards []Formula) {
     log := Log{}
     log.addr = addr
     log.addrVersion = addrVersion
@@ -47,15 +85,34 @@ func (ts *TransactionState) AddLog(addr Address, addrVersion uint64, codeAddr Ad
     log.data = data
     log.topics = make([]Formula, len(topics))
     copy(log.topics, topics)
+    log.guards = guards
     ts.logs = append(ts.logs, log)
 }
 
+// jump targets of returned frame are kept for logging, reverted frames are dropped with their state
+func (ts *TransactionState) PopFrame() {
+    se := ts.stacked.Cur()
+    for _, target := range se.jumpTargets {
+        ts.jumpTargets = append(ts.jumpTargets, JumpTarget{se.addr, se.addrVersion, se.codeAddr, target})
+    }
+    ts.stacked.Pop()
+}
+
 func (ts *TransactionState) CommitLogs() {
     for _, log := range ts.logs {
         ts.overlayDB.simpleDB.CommitFormulaWithShorts(log.data.hash)
         for _, topic := range log.topics {
             ts.overlayDB.simpleDB.CommitFormulaWithShorts(topic.hash)
         }
+        for _, guard := range log.guards {
+            ts.overlayDB.simpleDB.CommitFormulaWithShorts(guard.hash)
+        }
+    }
+    for _, jt := range ts.jumpTargets {
+        ts.overlayDB.simpleDB.CommitFormulaWithShorts(jt.target.hash)
+    }
+    for _, vt := range ts.valueTransfers {
+        ts.overlayDB.simpleDB.CommitFormulaWithShorts(vt.amount.hash)
     }
 }
 
@@ -73,9 +130,13 @@ func (ts *TransactionState) PrintLogs() {
 }
 
 type TransactionDB struct {
-    simpleDB   *SimpleDB
-    states     []*TransactionState
-    returndata []DEPByte
+    simpleDB     *SimpleDB
+    states       []*TransactionState
+    returndata   []DEPByte
+    returnGuards []Formula
+    // not a part of state, reads of reverted frames are witness too
+    witness      []WitnessRead
+    witnessKeys  map[witnessKey]bool
 }
 
 func TransactionDBCall(simpleDB *SimpleDB, addr, codeAddr Address, calldataBin []byte, code []byte) *TransactionDB {
@@ -84,7 +145,7 @@ func TransactionDBCall(simpleDB *SimpleDB, addr, codeAddr Address, calldataBin [
     t.states = []*TransactionState{transactionStateNew(simpleDB, false, addr, codeAddr)}
 
     calldata := FormulaDEPBytes(simpleDB.ConstantNewWithShorts(OPCallData, calldataBin))
-    t.Call(addr, addr, calldata, code)
+    t.Call(addr, codeAddr, calldata, code)
 
     return t
 }
@@ -95,54 +156,79 @@ func TransactionDBCreate(simpleDB *SimpleDB, addr, codeAddr Address, initcodeBin
     t.states = []*TransactionState{transactionStateNew(simpleDB, true, addr, codeAddr)}
 
     initcode := FormulaDEPBytes(simpleDB.ConstantNewWithShorts(OPInitCode, initcodeBin))
-    t.Create(addr, codeAddr, initcode, initcodeBin)
+    t.Create(addr, codeAddr, []DEPByte{}, initcode, initcodeBin)
 
     return t
 }
 
 func (t *TransactionDB) Commit() {
     if !t.IsCreate() {
-        t.simpleDB.logger.LogReturnData(t.Address(), t.AddressVersion(), t.CodeAddress(), t.returndata)
+        t.simpleDB.logger.LogReturnData(t.Address(), t.AddressVersion(), t.CodeAddress(), t.returndata, t.returnGuards)
     }
     for _, log := range t.curState().logs {
         t.simpleDB.logger.LogLog(log)
     }
+    for _, jt := range t.curState().jumpTargets {
+        t.simpleDB.logger.LogJumpTarget(jt)
+    }
+    for _, vt := range t.curState().valueTransfers {
+        t.simpleDB.logger.LogValueTransfer(vt)
+    }
+    if len(t.witness) > 0 {
+        t.simpleDB.logger.LogWitness(t.Address(), t.AddressVersion(), t.CodeAddress(), t.witness)
+    }
     
     t.curState().overlayDB.Commit()
     t.curState().CommitLogs()
     t.simpleDB.CommitDEPBytesWithShorts(t.returndata)
+    for _, guard := range t.returnGuards {
+        t.simpleDB.CommitFormulaWithShorts(guard.hash)
+    }
+    // value copied from earlier transaction is already saved
+    for _, read := range t.witness {
+        t.simpleDB.commitFormulaWithShortsInternal(read.value.hash, true)
+    }
 
     t.simpleDB.ResetFormulas()
 }
 
 func (t *TransactionDB) Call(addr, codeAddr Address, calldata []DEPByte, code []byte) {
+    guards := t.Guards()
     t.dupState()
     t.returndata = make([]DEPByte, 0)
     addrVersion := t.GetAddressVersion(addr)
     t.curState().stacked.Push(false, addr, addrVersion, codeAddr, calldata, t.GetCode(codeAddr, code), t.GetCodeHash(codeAddr, code), t.GetInitcodeHash(codeAddr, code))
+    t.curState().stacked.Cur().guards = guards
+    t.curState().stacked.Cur().eof = ParseEOF(code)
 }
 
-func (t *TransactionDB) Create(addr, codeAddr Address, initcode []DEPByte, initcodeBin []byte) {
+// calldata is empty for legacy creation, EOFCREATE passes input to initcode
+func (t *TransactionDB) Create(addr, codeAddr Address, calldata []DEPByte, initcode []DEPByte, initcodeBin []byte) {
+    guards := t.Guards()
     t.dupState()
     t.returndata = make([]DEPByte, 0)
     codeHash := CodeHash(initcodeBin)
     addrVersion := t.GetAddressVersion(addr)
-    t.curState().stacked.Push(true, addr, addrVersion, codeAddr, make([]DEPByte, 0), initcode, codeHash, codeHash)
+    t.curState().stacked.Push(true, addr, addrVersion, codeAddr, calldata, initcode, codeHash, codeHash)
+    t.curState().stacked.Cur().guards = guards
+    t.curState().stacked.Cur().eof = ParseEOF(initcodeBin)
 }
 
 func (t *TransactionDB) Revert(returndata []DEPByte) {
+    t.returnGuards = t.Guards()
     t.popState()
     t.returndata = CopyDEPBytes(returndata)
 }
 
 func (t *TransactionDB) Return(returndata []DEPByte, returndataBytes []byte) {
+    t.returnGuards = t.Guards()
     if t.IsCreate() {
         t.SetCode(returndata, returndataBytes, t.CodeHash())
         returndata = []DEPByte{}
     }
     t.returndata = CopyDEPBytes(returndata)
     t.setState(t.popState())
-    t.curState().stacked.Pop()
+    t.curState().PopFrame()
 }
 
 func (t *TransactionDB) Selfdestruct() {
@@ -178,11 +264,18 @@ func (t *TransactionDB) GetAddressVersion(addr Address) uint64 {
 }
 
 func (t *TransactionDB) GetSlot(slot *uint256.Int, value Hash) []DEPByte {
-    return t.curState().overlayDB.GetSlot(t.Address(), slot, value).data
+    res := t.curState().overlayDB.GetSlot(t.Address(), slot, value).data
+    if t.simpleDB.logger.toLog.Witness {
+        if sources := t.witnessSources(res, OPUnknownSlot, OPGenesisSlot); len(sources) > 0 {
+            slotBin := slot.Bytes32()
+            t.AddWitness(t.Address(), "slot", slotBin[:], sources[0])
+        }
+    }
+    return res
 }
 
 func (t *TransactionDB) SetSlot(slot *uint256.Int, val []DEPByte) {
-    t.curState().overlayDB.SetSlot(t.Address(), t.CodeAddress(), slot, val)
+    t.curState().overlayDB.SetSlot(t.Address(), t.CodeAddress(), slot, val, t.Guards())
 }
 
 func (t *TransactionDB) GetTransient(slot *uint256.Int) []DEPByte {
@@ -190,11 +283,17 @@ func (t *TransactionDB) GetTransient(slot *uint256.Int) []DEPByte {
 }
 
 func (t *TransactionDB) SetTransient(slot *uint256.Int, val []DEPByte) {
-    t.curState().overlayDB.SetTransient(t.Address(), slot, val)
+    t.curState().overlayDB.SetTransient(t.Address(), t.CodeAddress(), slot, val, t.Guards())
 }
 
 func (t *TransactionDB) GetCode(addr Address, code []byte) []DEPByte {
-    return t.curState().overlayDB.GetCode(addr, code).data
+    res := t.curState().overlayDB.GetCode(addr, code).data
+    if t.simpleDB.logger.toLog.Witness {
+        if sources := t.witnessSources(res, OPUnknownCode, OPGenesisCode); len(sources) > 0 {
+            t.AddWitness(addr, "code", []byte{}, sources[0])
+        }
+    }
+    return res
 }
 
 func (t *TransactionDB) GetCodeHash(addr Address, code []byte) Hash {
@@ -209,6 +308,50 @@ func (t *TransactionDB) SetCode(val []DEPByte, valBytes []byte, initcodeHash Has
     t.curState().overlayDB.SetCode(t.Address(), t.CodeAddress(), val, valBytes, initcodeHash)
 }
 
+// authorizations are applied before execution and are kept even if it reverts
+func (t *TransactionDB) SetDelegation(addr Address, val []DEPByte, valBytes []byte) {
+    for _, s := range t.states {
+        s.overlayDB.SetDelegation(addr, val, valBytes)
+    }
+}
+
+func (t *TransactionDB) SetCallOp(op byte) {
+    t.curState().stacked.Cur().callOp = op
+}
+
+func (t *TransactionDB) SetDelegated() {
+    t.curState().stacked.Cur().delegated = true
+}
+
+// replaces code of current frame before it is executed (authorization of transaction target)
+func (t *TransactionDB) SetFrameCode(codeAddr Address, code []byte, delegated bool) {
+    cur := t.curState().stacked.Cur()
+    cur.codeAddr = codeAddr
+    cur.code = t.GetCode(codeAddr, code)
+    cur.codeHash = t.GetCodeHash(codeAddr, code)
+    cur.initcodeHash = t.GetInitcodeHash(codeAddr, code)
+    cur.codeFormulas = nil
+    cur.eof = ParseEOF(code)
+    cur.delegated = delegated
+}
+
+func (t *TransactionDB) CallKind() string {
+    cur := t.curState().stacked.Cur()
+    if cur.isCreate {
+        return "create"
+    }
+    if cur.delegated {
+        return "delegated"
+    }
+    switch cur.callOp {
+    case byte(CALLCODE):
+        return "callcode"
+    case byte(DELEGATECALL), byte(EXTDELEGATECALL):
+        return "delegatecall"
+    }
+    return "call"
+}
+
 func (t *TransactionDB) Address() Address {
     return t.curState().stacked.Cur().addr
 }
@@ -241,6 +384,63 @@ func (t *TransactionDB) Code() []DEPByte {
     return CopyDEPBytes(t.curState().stacked.Cur().code)
 }
 
+func (t *TransactionDB) EOF() *EOFContainer {
+    return t.curState().stacked.Cur().eof
+}
+
+// position inside of Code(), pc of eof code is relative to current code section
+func (t *TransactionDB) CodeOffset(pc uint64) uint64 {
+    cur := t.curState().stacked.Cur()
+    if cur.eof == nil {
+        return pc
+    }
+    return cur.eof.CodeOffset(cur.section, pc)
+}
+
+func (t *TransactionDB) EOFImmediate(pc uint64, size uint64) []byte {
+    cur := t.curState().stacked.Cur()
+    if cur.eof == nil {
+        panic("eof opcode in legacy code")
+    }
+    return cur.eof.Immediate(cur.section, pc, size)
+}
+
+func (t *TransactionDB) EOFData() []DEPByte {
+    cur := t.curState().stacked.Cur()
+    if cur.eof == nil {
+        panic("eof opcode in legacy code")
+    }
+    return CopyDEPBytes(cur.code[cur.eof.dataOffset:])
+}
+
+func (t *TransactionDB) EOFSubcontainer(index uint8) ([]DEPByte, []byte) {
+    cur := t.curState().stacked.Cur()
+    if cur.eof == nil {
+        panic("eof opcode in legacy code")
+    }
+    offset, size := cur.eof.Subcontainer(index)
+    return CopyDEPBytes(cur.code[offset:offset+size]), cur.eof.code[offset:offset+size]
+}
+
+func (t *TransactionDB) EnterCodeSection(section uint16) {
+    cur := t.curState().stacked.Cur()
+    cur.returnSections = append(cur.returnSections, cur.section)
+    cur.section = section
+}
+
+func (t *TransactionDB) JumpCodeSection(section uint16) {
+    t.curState().stacked.Cur().section = section
+}
+
+func (t *TransactionDB) LeaveCodeSection() {
+    cur := t.curState().stacked.Cur()
+    if len(cur.returnSections) == 0 {
+        panic("RETF without CALLF")
+    }
+    cur.section = cur.returnSections[len(cur.returnSections)-1]
+    cur.returnSections = cur.returnSections[:len(cur.returnSections)-1]
+}
+
 func (t *TransactionDB) Stack() *Stack {
     return t.curState().stacked.Cur().stack
 }
@@ -250,7 +450,64 @@ func (t *TransactionDB) Memory() *Memory {
 }
 
 func (t *TransactionDB) AddLog(data Formula, topics []Formula) {
-    t.curState().AddLog(t.Address(), t.AddressVersion(), t.CodeAddress(), data, topics)
+    t.curState().AddLog(t.Address(), t.AddressVersion(), t.CodeAddress(), data, topics, t.Guards())
+}
+
+// transfer from caller frame to current one, kept in callee state so it is dropped on revert
+func (t *TransactionDB) AddValueTransfer(amount Formula) {
+    elements := t.curState().stacked.elements
+    if len(elements) < 2 {
+        panic("value transfer without caller")
+    }
+    caller := elements[len(elements)-2]
+    vt := ValueTransfer{caller.addr, caller.addrVersion, caller.codeAddr, t.Address(), amount}
+    t.curState().valueTransfers = append(t.curState().valueTransfers, vt)
+}
+
+func (t *TransactionDB) SetCallContext(value, gas []DEPByte) {
+    cur := t.curState().stacked.Cur()
+    if value != nil {
+        cur.callValue = CopyDEPBytes(value)
+    }
+    if gas != nil {
+        cur.callGas = CopyDEPBytes(gas)
+    }
+}
+
+func (t *TransactionDB) CallValue() []DEPByte {
+    value := t.curState().stacked.Cur().callValue
+    if value == nil {
+        return nil
+    }
+    return CopyDEPBytes(value)
+}
+
+func (t *TransactionDB) CallGas() []DEPByte {
+    gas := t.curState().stacked.Cur().callGas
+    if gas == nil {
+        return nil
+    }
+    return CopyDEPBytes(gas)
+}
+
+func (t *TransactionDB) AddJumpTarget(target Formula) {
+    t.curState().stacked.Cur().AddJumpTarget(target)
+}
+
+func (t *TransactionDB) IsCodeConstant(val []DEPByte) bool {
+    return t.curState().stacked.Cur().IsCodeConstant(val)
+}
+
+func (t *TransactionDB) AddGuard(pc uint64, guard Formula) {
+    t.curState().stacked.Cur().AddGuard(t.CodeOffset(pc), guard)
+}
+
+// guards of the current frame, empty if implicit flow is disabled
+func (t *TransactionDB) Guards() []Formula {
+    guards := t.curState().stacked.Cur().guards
+    res := make([]Formula, len(guards))
+    copy(res, guards)
+    return res
 }
 
 func (t *TransactionDB) Returndata() []DEPByte {
@@ -313,3 +570,52 @@ func (t *TransactionDB) DebugPrintState() {
     fmt.Print("RETURNDATA: "); t.PrintData(t.returndata)
     fmt.Println("-----------------------------------------------------------------------")
 }
+
+func (t *TransactionDB) AddWitness(addr Address, kind string, key []byte, value Formula) {
+    if !t.simpleDB.logger.toLog.Witness {
+        return
+    }
+    k := witnessKey{addr, kind, string(key)}
+    if t.witnessKeys == nil {
+        t.witnessKeys = map[witnessKey]bool{}
+    }
+    if t.witnessKeys[k] {
+        return
+    }
+    t.witnessKeys[k] = true
+    t.witness = append(t.witness, WitnessRead{addr, kind, append([]byte{}, key...), value})
+}
+
+// unknown or genesis formulas the value consists of, also when it was copied
+// from them through storage, slices and concatenations
+func (t *TransactionDB) witnessSources(value []DEPByte, unknownOp, genesisOp uint8) []Formula {
+    res := []Formula{}
+    visited := map[Hash]bool{}
+    queue := []Hash{}
+    for _, b := range value {
+        if !visited[b.formula] {
+            visited[b.formula] = true
+            queue = append(queue, b.formula)
+        }
+    }
+    for len(queue) > 0 {
+        formula := t.simpleDB.GetFormula(queue[0])
+        queue = queue[1:]
+        next := []Hash{}
+        switch formula.opcode {
+        case unknownOp, genesisOp:
+            res = append(res, formula)
+        case OPSLoad, OPSStore, OPTLoad, OPTStore, OPSlice:
+            next = formula.operands[:1]
+        case OPConcat:
+            next = formula.operands
+        }
+        for _, hash := range next {
+            if !visited[hash] {
+                visited[hash] = true
+                queue = append(queue, hash)
+            }
+        }
+    }
+    return res
+}
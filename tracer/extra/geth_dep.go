@@ -1,6 +1,8 @@
 package live
 
 import (
+    "fmt"
+    "bytes"
     "math/big"
     "encoding/json"
 
@@ -10,12 +12,14 @@ import (
     "github.com/ethereum/go-ethereum/core/types"
     "github.com/ethereum/go-ethereum/eth/tracers"
     "github.com/ethereum/go-ethereum/core/tracing"
+    "github.com/ethereum/go-ethereum/params"
 
     "github.com/ethereum/go-ethereum/eth/tracers/live/dep_tracer"
 )
 
 func init() {
     tracers.LiveDirectory.Register("dep", NewDep)
+    tracers.DefaultDirectory.Register("depTracer", NewDepTracer, false)
 }
 
 type Dep struct {
@@ -23,8 +27,11 @@ type Dep struct {
     writingBlock          bool
     transacting           bool
     selfdestructProtector bool
+    systemCall            *tracing.VMContext
     blockNumber           *big.Int
     time                  uint64
+    // set by OnBlockchainInit for live tracer, passed to constructor of on demand tracer
+    chainConfig           *params.ChainConfig
 }
 
 func NewDep(cfg json.RawMessage) (*tracing.Hooks, error) {
@@ -32,11 +39,137 @@ func NewDep(cfg json.RawMessage) (*tracing.Hooks, error) {
         writingBlock:          false,
         transacting:           false,
         selfdestructProtector: false,
+        systemCall:            nil,
         handler:               dep_tracer.NewDepHandler(cfg, nil),
         blockNumber:           nil,
         time:                  0,
+        chainConfig:           nil,
     }
+    return t.hooks(), nil
+}
+
+// collects output of on demand tracer, events of json logger are printed line by line
+type depResult struct {
+    data   []byte
+    reason error
+}
+func (r *depResult) Write(data []byte) {
+    r.data = append(r.data, data...)
+}
+
+func (r *depResult) GetResult() (json.RawMessage, error) {
+    if r.reason != nil {
+        return nil, r.reason
+    }
+    events := []json.RawMessage{}
+    for _, line := range bytes.Split(r.data, []byte("\n")) {
+        if len(bytes.TrimSpace(line)) > 0 {
+            events = append(events, json.RawMessage(line))
+        }
+    }
+    return json.Marshal(events)
+}
+
+func (r *depResult) Stop(err error) {
+    r.reason = err
+}
+
+// on demand tracer for debug_traceTransaction and similar calls, state before the transaction
+// is not known to TracEVM, so by default amnesia kv is used (past_unknown), output is returned
+// as json list of logger events
+func NewDepTracer(ctx *tracers.Context, cfg json.RawMessage, chainConfig *params.ChainConfig) (*tracers.Tracer, error) {
+    config := map[string]any{}
+    if len(cfg) > 0 {
+        if err := json.Unmarshal(cfg, &config); err != nil {
+            return nil, err
+        }
+    }
+    // config comes from remote caller, so options which touch files or connections of the
+    // node are rejected, only temporary kv is allowed
+    for _, key := range []string{"output", "record", "genesis"} {
+        if _, ok := config[key]; ok {
+            return nil, fmt.Errorf("depTracer: %s is not allowed", key)
+        }
+    }
+    // precompiles are defined by the chain of the node, and filter has nothing to select
+    // when single transaction is traced
+    for _, key := range []string{"precompiles", "filter"} {
+        if _, ok := config[key]; ok {
+            return nil, fmt.Errorf("depTracer: %s is not allowed for on demand tracing", key)
+        }
+    }
+    engine := "amnesia"
+    if kv, ok := config["kv"]; ok {
+        kvConfig, ok := kv.(map[string]any)
+        if !ok {
+            return nil, fmt.Errorf("depTracer: invalid kv")
+        }
+        engine, _ = kvConfig["engine"].(string)
+        if engine != "amnesia" && engine != "memory" {
+            return nil, fmt.Errorf("depTracer: kv engine %q is not allowed, use amnesia or memory", kvConfig["engine"])
+        }
+    }
+    config["kv"] = map[string]any{"engine": engine}
+    logger, ok := config["logger"].(map[string]any)
+    if ok {
+        for _, key := range []string{"abi", "storage_layouts"} {
+            if _, ok := logger[key]; ok {
+                return nil, fmt.Errorf("depTracer: logger %s is not allowed", key)
+            }
+        }
+    } else {
+        logger = map[string]any{
+            "final_slots_short": true,
+            "final_slots":       true,
+            "return_data":       true,
+            "logs":              true,
+            "sol_view":          true,
+        }
+    }
+    logger["output_format"] = "json"
+    config["logger"] = logger
+
+    cfg, err := json.Marshal(config)
+    if err != nil {
+        return nil, err
+    }
+
+    result := &depResult{}
+    handler, err := newDepHandler(cfg, result)
+    if err != nil {
+        return nil, err
+    }
+    // there is no OnBlockStart for a single transaction
+    t := &Dep{
+        writingBlock:          true,
+        transacting:           false,
+        selfdestructProtector: false,
+        systemCall:            nil,
+        handler:               handler,
+        blockNumber:           ctx.BlockNumber,
+        time:                  0,
+        chainConfig:           chainConfig,
+    }
+    return &tracers.Tracer{
+        Hooks:     t.recoveringHooks(result),
+        GetResult: result.GetResult,
+        Stop:      result.Stop,
+    }, nil
+}
+
+// config comes from rpc request, so invalid one should not crash the node
+func newDepHandler(cfg json.RawMessage, result *depResult) (handler *dep_tracer.DepHandler, err error) {
+    defer func() {
+        if p := recover(); p != nil {
+            err = fmt.Errorf("depTracer: %v", p)
+        }
+    }()
+    return dep_tracer.NewDepHandler(cfg, result), nil
+}
+
+func (t *Dep) hooks() *tracing.Hooks {
     return &tracing.Hooks{
+        OnBlockchainInit: t.OnBlockchainInit,
         OnBlockStart: t.OnBlockStart,
         OnBlockEnd: t.OnBlockEnd,
         OnTxStart: t.OnTxStart,
@@ -45,7 +178,103 @@ func NewDep(cfg json.RawMessage) (*tracing.Hooks, error) {
         OnEnter: t.OnEnter,
         OnFault: t.OnFault,
         OnExit: t.OnExit,
-    }, nil
+        OnCodeChangeV2: t.OnCodeChangeV2,
+        OnSystemCallStartV2: t.OnSystemCallStartV2,
+        OnSystemCallEnd: t.OnSystemCallEnd,
+    }
+}
+
+// api of geth does not recover panics of tracers, so panic of on demand tracer is returned as
+// error of the result instead of crashing the node, the rest of the transaction is not traced
+func (t *Dep) recoveringHooks(result *depResult) *tracing.Hooks {
+    hooks := t.hooks()
+    recoverPanic := func() {
+        if p := recover(); p != nil {
+            result.Stop(fmt.Errorf("depTracer: %v", p))
+        }
+    }
+    failed := func() bool {
+        return result.reason != nil
+    }
+    return &tracing.Hooks{
+        OnBlockStart: func(ev tracing.BlockEvent) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnBlockStart(ev)
+        },
+        OnBlockEnd: func(err error) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnBlockEnd(err)
+        },
+        OnTxStart: func(vm *tracing.VMContext, tx *types.Transaction, from common.Address) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnTxStart(vm, tx, from)
+        },
+        OnTxEnd: func(receipt *types.Receipt, err error) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnTxEnd(receipt, err)
+        },
+        OnOpcode: func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
+        },
+        OnEnter: func(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnEnter(depth, typ, from, to, input, gas, value)
+        },
+        OnFault: func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, depth int, err error) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnFault(pc, op, gas, cost, scope, depth, err)
+        },
+        OnExit: func(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnExit(depth, output, gasUsed, err, reverted)
+        },
+        OnCodeChangeV2: func(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte, reason tracing.CodeChangeReason) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnCodeChangeV2(addr, prevCodeHash, prevCode, codeHash, code, reason)
+        },
+        OnSystemCallStartV2: func(vm *tracing.VMContext) {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnSystemCallStartV2(vm)
+        },
+        OnSystemCallEnd: func() {
+            if failed() {
+                return
+            }
+            defer recoverPanic()
+            hooks.OnSystemCallEnd()
+        },
+    }
 }
 
 type StateDB struct {
@@ -58,6 +287,18 @@ func (s StateDB) GetCode(addr [20]byte) []byte {
     return s.stateDB.GetCode(addr)
 }
 
+func (t *Dep) OnBlockchainInit(chainConfig *params.ChainConfig) {
+    t.chainConfig = chainConfig
+}
+
+// VMContext of pinned geth (see build.py) does not carry chain config
+func (t *Dep) forks(vm *tracing.VMContext) (isSelfdestruct6780 bool, isRandom bool) {
+    if t.chainConfig == nil {
+        panic("chain config is not known, OnBlockchainInit was not called")
+    }
+    return t.chainConfig.IsCancun(vm.BlockNumber, vm.Time), t.chainConfig.IsLondon(vm.BlockNumber)
+}
+
 func (t *Dep) OnBlockStart(ev tracing.BlockEvent) {
     if t.writingBlock {
         panic("OnBlockStart called during writingBlock state")
@@ -66,6 +307,9 @@ func (t *Dep) OnBlockStart(ev tracing.BlockEvent) {
 
     t.blockNumber = ev.Block.Number()
     t.time = ev.Block.Time()
+
+    // block with unexpected parent means reorg, retracted blocks are rolled back
+    t.handler.StartBlock(ev.Block.Number(), ev.Block.Hash(), ev.Block.ParentHash())
 }
 
 func (t *Dep) OnBlockEnd(err error) {
@@ -73,27 +317,34 @@ func (t *Dep) OnBlockEnd(err error) {
         return
     }
     t.writingBlock = false
+
+    // writes of invalid block are discarded
+    t.handler.EndBlock(err != nil)
 }
 
 func (t *Dep) OnTxStart(vm *tracing.VMContext, tx *types.Transaction, from common.Address) {
     if !t.writingBlock {
         return
     }
-    t.transacting = true
 
     create := tx.To() == nil
     var addr common.Address
-    var code []byte
     if create {
         addr = crypto.CreateAddress(from, tx.Nonce())
-        code = nil
     } else {
         addr = *tx.To()
+    }
+    if !t.handler.ShouldRecordTransaction(addr, from, vm.BlockNumber) {
+        return
+    }
+    t.transacting = true
+
+    var code []byte
+    if !create {
         code = vm.StateDB.GetCode(addr)
     }
 
-    isSelfdestruct6780 := vm.ChainConfig.IsCancun(t.blockNumber, t.time)
-    isRandom := vm.ChainConfig.IsLondon(t.blockNumber)
+    isSelfdestruct6780, isRandom := t.forks(vm)
 
     t.handler.StartTransactionRecording(create, addr, tx.Data(), vm.BlockNumber, vm.Time, from, tx.Hash(), code, isSelfdestruct6780, isRandom, StateDB{vm.StateDB})
 }
@@ -107,6 +358,52 @@ func (t *Dep) OnTxEnd(receipt *types.Receipt, err error) {
     t.handler.EndTransactionRecording()
 }
 
+// system calls are made by block processing outside of transactions, target is known only in OnEnter
+func (t *Dep) OnSystemCallStartV2(vm *tracing.VMContext) {
+    if !t.writingBlock || t.transacting {
+        return
+    }
+    t.systemCall = vm
+}
+
+func (t *Dep) OnSystemCallEnd() {
+    if t.systemCall == nil {
+        return
+    }
+    t.systemCall = nil
+    if !t.transacting {
+        return
+    }
+    t.transacting = false
+
+    t.handler.EndTransactionRecording()
+}
+
+func (t *Dep) startSystemCall(from, to common.Address, input []byte) {
+    vm := t.systemCall
+    if !t.handler.ShouldRecordTransaction(to, from, vm.BlockNumber) {
+        return
+    }
+    t.transacting = true
+
+    isSelfdestruct6780, isRandom := t.forks(vm)
+
+    t.handler.StartSystemCallRecording(to, input, vm.BlockNumber, vm.Time, vm.StateDB.GetCode(to), isSelfdestruct6780, isRandom, StateDB{vm.StateDB})
+}
+
+// authorizations of set code transactions are applied after OnTxStart and before execution
+func (t *Dep) OnCodeChangeV2(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte, reason tracing.CodeChangeReason) {
+    if !t.transacting {
+        return
+    }
+    if reason != tracing.CodeChangeAuthorization && reason != tracing.CodeChangeAuthorizationClear {
+        return
+    }
+
+    delegate, _ := dep_tracer.ParseDelegation(code)
+    t.handler.HandleAuthorization(addr, delegate)
+}
+
 func (t *Dep) OnOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
     if !t.transacting {
         return
@@ -127,6 +424,9 @@ func (t *Dep) OnOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpCon
 }
 
 func (t *Dep) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+    if t.systemCall != nil && !t.transacting && depth == 0 {
+        t.startSystemCall(from, to, input)
+    }
     if !t.transacting {
         return
     }
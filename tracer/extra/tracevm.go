@@ -0,0 +1,214 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "flag"
+    "context"
+    "math/big"
+    "encoding/json"
+
+    "github.com/holiman/uint256"
+
+    "github.com/ethereum/go-ethereum/core"
+    "github.com/ethereum/go-ethereum/core/vm"
+    "github.com/ethereum/go-ethereum/core/state"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/core/tracing"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/params"
+
+    "github.com/ethereum/go-ethereum/eth/tracers/live"
+)
+
+// accounts of prestate are not known to tracer, so past_unknown is required
+const defaultConfig = `{
+    "kv": {"engine": "amnesia"},
+    "logger": {
+        "opcodes_short": [], "opcodes": [],
+        "final_slots": true, "codes": true, "return_data": true, "logs": true,
+        "sol_view": true
+    },
+    "past_unknown": true
+}`
+
+func fail(format string, args ...any) {
+    fmt.Fprintf(os.Stderr, format + "\n", args...)
+    os.Exit(1)
+}
+
+// accepts both genesis json (with "alloc" field) and plain alloc, like genesis option of TracEVM
+func readAlloc(path string) types.GenesisAlloc {
+    var file struct {
+        Alloc types.GenesisAlloc `json:"alloc"`
+    }
+    readJSON(path, &file)
+    if file.Alloc != nil {
+        return file.Alloc
+    }
+    alloc := types.GenesisAlloc{}
+    readJSON(path, &alloc)
+    return alloc
+}
+
+func readJSON(path string, v any) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        fail("failed to read %s: %v", path, err)
+    }
+    if err := json.Unmarshal(data, v); err != nil {
+        fail("failed to parse %s: %v", path, err)
+    }
+}
+
+// executes single transaction on top of prestate (alloc json: address => balance, nonce,
+// code, storage) and prints output of TracEVM, exits with 2 if the transaction reverted
+func main() {
+    var (
+        configPath  = flag.String("config", "", "TracEVM config (by default amnesia kv, past_unknown)")
+        prestate    = flag.String("prestate", "", "prestate/alloc json")
+        chainConfig = flag.String("chainconfig", "", "chain config json (by default all forks are enabled)")
+        from        = flag.String("from", "0x00000000000000000000000000000000000000ee", "sender")
+        to          = flag.String("to", "", "receiver, empty for contract creation")
+        input       = flag.String("input", "0x", "calldata or initcode (hex)")
+        value       = flag.String("value", "0", "value in wei")
+        gas         = flag.Uint64("gas", 30_000_000, "gas limit")
+        number      = flag.Uint64("number", 1, "block number")
+        timestamp   = flag.Uint64("timestamp", 1, "block timestamp")
+        coinbase    = flag.String("coinbase", "0x0000000000000000000000000000000000000000", "block coinbase")
+        baseFee     = flag.Uint64("basefee", 0, "block base fee")
+    )
+    flag.Parse()
+
+    cfg := json.RawMessage(defaultConfig)
+    if *configPath != "" {
+        data, err := os.ReadFile(*configPath)
+        if err != nil {
+            fail("failed to read %s: %v", *configPath, err)
+        }
+        cfg = data
+    }
+
+    config := params.MergedTestChainConfig
+    if *chainConfig != "" {
+        config = new(params.ChainConfig)
+        readJSON(*chainConfig, config)
+    }
+
+    // genesis of config is imported by TracEVM, so EVM starts from it too, prestate overrides it
+    var genesis struct {
+        Genesis string `json:"genesis"`
+    }
+    if err := json.Unmarshal(cfg, &genesis); err != nil {
+        fail("failed to parse config: %v", err)
+    }
+    alloc := types.GenesisAlloc{}
+    if genesis.Genesis != "" {
+        for addr, account := range readAlloc(genesis.Genesis) {
+            alloc[addr] = account
+        }
+    }
+    if *prestate != "" {
+        for addr, account := range readAlloc(*prestate) {
+            alloc[addr] = account
+        }
+    }
+    statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+    if err != nil {
+        fail("failed to create state: %v", err)
+    }
+    for addr, account := range alloc {
+        statedb.SetCode(addr, account.Code, tracing.CodeChangeGenesis)
+        statedb.SetNonce(addr, account.Nonce, tracing.NonceChangeGenesis)
+        if account.Balance != nil {
+            statedb.SetBalance(addr, uint256.MustFromBig(account.Balance), tracing.BalanceIncreaseGenesisBalance)
+        }
+        for k, v := range account.Storage {
+            statedb.SetState(addr, k, v)
+        }
+    }
+    statedb.Finalise(params.Rules{})
+
+    data, err := hexutil.Decode(*input)
+    if err != nil {
+        fail("invalid input: %v", err)
+    }
+    amount, err := uint256.FromDecimal(*value)
+    if err != nil {
+        fail("invalid value: %v", err)
+    }
+    sender := common.HexToAddress(*from)
+    var receiver *common.Address
+    if *to != "" {
+        addr := common.HexToAddress(*to)
+        receiver = &addr
+    }
+
+    hooks, err := live.NewDep(cfg)
+    if err != nil {
+        fail("failed to create tracer: %v", err)
+    }
+
+    header := &types.Header{
+        Number:     new(big.Int).SetUint64(*number),
+        Time:       *timestamp,
+        Coinbase:   common.HexToAddress(*coinbase),
+        GasLimit:   *gas,
+        BaseFee:    new(big.Int).SetUint64(*baseFee),
+        Difficulty: big.NewInt(0),
+        MixDigest:  common.Hash{},
+    }
+    random := header.MixDigest
+    blockContext := vm.BlockContext{
+        CanTransfer: core.CanTransfer,
+        Transfer:    core.Transfer,
+        GetHash:     func(n uint64) common.Hash { return common.BigToHash(new(big.Int).SetUint64(n)) },
+        Coinbase:    header.Coinbase,
+        GasLimit:    header.GasLimit,
+        BlockNumber: header.Number,
+        Time:        header.Time,
+        Difficulty:  header.Difficulty,
+        BaseFee:     header.BaseFee,
+        BlobBaseFee: big.NewInt(0),
+        Random:      &random,
+    }
+    evm := vm.NewEVM(blockContext, state.NewHookedState(statedb, hooks), config, vm.Config{Tracer: hooks, NoBaseFee: true})
+
+    nonce := statedb.GetNonce(sender)
+    tx := types.NewTx(&types.LegacyTx{
+        Nonce:    nonce,
+        To:       receiver,
+        Value:    amount.ToBig(),
+        Gas:      *gas,
+        GasPrice: big.NewInt(0),
+        Data:     data,
+    })
+    msg := &core.Message{
+        To:                    receiver,
+        From:                  sender,
+        Nonce:                 nonce,
+        Value:                 amount,
+        GasLimit:              *gas,
+        GasPrice:              new(uint256.Int),
+        GasFeeCap:             new(uint256.Int),
+        GasTipCap:             new(uint256.Int),
+        Data:                  data,
+        SkipNonceChecks:       true,
+        SkipTransactionChecks: true,
+    }
+
+    // hooks are called the same way as by block processing of geth
+    hooks.OnBlockchainInit(config)
+    hooks.OnBlockStart(tracing.BlockEvent{Block: types.NewBlockWithHeader(header)})
+    statedb.SetTxContext(tx.Hash(), 0, 1)
+    receipt, _, err := core.ApplyTransactionWithEVM(context.Background(), msg, core.NewGasPool(*gas), statedb, header.Number, header.Hash(), header.Time, tx, evm)
+    hooks.OnBlockEnd(err)
+    if err != nil {
+        fail("failed to apply transaction: %v", err)
+    }
+    if receipt.Status != types.ReceiptStatusSuccessful {
+        fmt.Fprintln(os.Stderr, "transaction reverted")
+        os.Exit(2)
+    }
+}
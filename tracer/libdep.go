@@ -22,24 +22,26 @@ typedef struct {
     int   size;
 } Stack;
 
-typedef uint64_t (*get_nonce_function) (Address address);
-inline uint64_t get_nonce_bridge(get_nonce_function f, Address address) {
-    return f(address);
+typedef uint64_t (*get_nonce_function) (void *user_data, Address address);
+inline uint64_t get_nonce_bridge(get_nonce_function f, void *user_data, Address address) {
+    return f(user_data, address);
 }
 
-typedef SizedArray (*get_code_function) (Address address);
-inline SizedArray get_code_bridge(get_code_function f, Address address) {
-    return f(address);
+typedef SizedArray (*get_code_function) (void *user_data, Address address);
+inline SizedArray get_code_bridge(get_code_function f, void *user_data, Address address) {
+    return f(user_data, address);
 }
 
-typedef uint64_t (*log_data_function) (char *data);
-inline void log_data_bridge(log_data_function f, char *data) {
-    f(data);
+typedef uint64_t (*log_data_function) (void *user_data, char *data);
+inline void log_data_bridge(log_data_function f, void *user_data, char *data) {
+    f(user_data, data);
 }
 */
 import "C"
 
 import (
+    "fmt"
+    "sync"
     "unsafe"
     "math/big"
     "github.com/holiman/uint256"
@@ -49,21 +51,92 @@ import (
 
 func main() {}
 
+// every InitDep creates separate instance, so that several tracers (for example of parallel
+// tests) can live in one process, instance is referred by opaque handle
+type depInstance struct {
+    handler  *dep_tracer.DepHandler
+    tracing  bool
+    getNonce C.get_nonce_function
+    getCode  C.get_code_function
+    userData unsafe.Pointer
+}
+
 var (
-    getNoncePointer       C.get_nonce_function        = nil
-    getCodePointer        C.get_code_function         = nil
-    cDepHandler           *dep_tracer.DepHandler      = nil
-    cTracing              bool                        = false
+    instancesLock sync.Mutex
+    instances     = map[uint64]*depInstance{}
+    lastHandle    uint64 = 0
+    // last error message of each handle (0 for failed InitDep), kept until the next error
+    lastErrors    = map[uint64]*C.char{}
 )
 
+// returned by exported functions, message of the error can be retrieved with DepLastError
+const (
+    depOK    C.int = 0
+    depError C.int = 1
+)
+
+// state of handler is unknown after panic, so panic of abort is ignored
+func abortTransaction(handler *dep_tracer.DepHandler) {
+    defer func() { recover() }()
+    handler.AbortTransaction()
+}
+
+// panics must not cross FFI boundary (go runtime aborts host process), so each exported
+// function recovers them, instance drops the current transaction after error and
+// traces the next one
+func recoverError(handle uint64, res *C.int) {
+    p := recover()
+    if p == nil {
+        return
+    }
+    instancesLock.Lock()
+    defer instancesLock.Unlock()
+    if instance, ok := instances[handle]; ok {
+        instance.tracing = false
+        abortTransaction(instance.handler)
+    }
+    if prev, ok := lastErrors[handle]; ok {
+        C.free(unsafe.Pointer(prev))
+    }
+    lastErrors[handle] = C.CString(fmt.Sprint(p))
+    *res = depError
+}
+
+// returned string is valid until the next error of the handle or FreeDep, nil if there was no error
+//export DepLastError
+func DepLastError(handle uint64) *C.char {
+    instancesLock.Lock()
+    defer instancesLock.Unlock()
+    return lastErrors[handle]
+}
+
+func getInstance(handle uint64) *depInstance {
+    instancesLock.Lock()
+    defer instancesLock.Unlock()
+    instance, ok := instances[handle]
+    if !ok {
+        panic("unknown dep handle")
+    }
+    return instance
+}
 
 //export RegisterGetNonce
-func RegisterGetNonce(pointer C.get_nonce_function) {
-   getNoncePointer = pointer
+func RegisterGetNonce(handle uint64, pointer C.get_nonce_function) (res C.int) {
+    defer recoverError(handle, &res)
+    if pointer == nil {
+        panic("get_nonce callback is NULL")
+    }
+    getInstance(handle).getNonce = pointer
+    return depOK
 }
 //export RegisterGetCode
-func RegisterGetCode(pointer C.get_code_function) {
-   getCodePointer = pointer
+func RegisterGetCode(handle uint64, pointer C.get_code_function) (res C.int) {
+    defer recoverError(handle, &res)
+    if pointer == nil {
+        panic("get_code callback is NULL")
+    }
+    getInstance(handle).getCode = pointer
+    return depOK
 }
 
 func packAddress(addr dep_tracer.Address) C.Address {
@@ -102,98 +175,172 @@ func unpackStack(data C.Stack) []uint256.Int {
     return res
 }
 
-type StateDBC struct {}
+type StateDBC struct {
+    instance *depInstance
+}
 func (s StateDBC) GetNonce(addr [20]byte) uint64 {
-    res := C.get_nonce_bridge(getNoncePointer, packAddress(addr))
+    res := C.get_nonce_bridge(s.instance.getNonce, s.instance.userData, packAddress(addr))
     return uint64(res)
 }
 func (s StateDBC) GetCode(addr [20]byte) []byte {
-    res := C.get_code_bridge(getCodePointer, packAddress(addr))
+    res := C.get_code_bridge(s.instance.getCode, s.instance.userData, packAddress(addr))
     return unpackSizedArray(res)
 }
 
 type CallbackWriterCB struct {
-    pointer C.log_data_function
+    pointer  C.log_data_function
+    userData unsafe.Pointer
 }
 func (cw CallbackWriterCB) Write(data []byte) {
     cdata := C.CString(string(data))
-    C.log_data_bridge(cw.pointer, cdata)
+    C.log_data_bridge(cw.pointer, cw.userData, cdata)
     C.free(unsafe.Pointer(cdata))
 }
 
+// userData is passed as is to all callbacks of the instance, 0 is returned on error
 //export InitDep
-func InitDep(cfg *C.char, pointer C.log_data_function) {
-    if cDepHandler != nil {
-        panic("InitDep called twice")
+func InitDep(cfg *C.char, pointer C.log_data_function, userData unsafe.Pointer) (handle uint64) {
+    var res C.int
+    defer func() {
+        if res != depOK {
+            handle = 0
+        }
+    }()
+    defer recoverError(0, &res)
+    instance := &depInstance{
+        handler:  nil,
+        tracing:  false,
+        getNonce: nil,
+        getCode:  nil,
+        userData: userData,
     }
     if pointer == nil {
-        cDepHandler = dep_tracer.NewDepHandler([]byte(C.GoString(cfg)), nil)
+        instance.handler = dep_tracer.NewDepHandler([]byte(C.GoString(cfg)), nil)
     } else {
-        cDepHandler = dep_tracer.NewDepHandler([]byte(C.GoString(cfg)), CallbackWriterCB{pointer})        
+        instance.handler = dep_tracer.NewDepHandler([]byte(C.GoString(cfg)), CallbackWriterCB{pointer, userData})
     }
+
+    instancesLock.Lock()
+    defer instancesLock.Unlock()
+    lastHandle++
+    instances[lastHandle] = instance
+    return lastHandle
+}
+
+// instance stays registered if closing fails, so that the error can be retrieved
+//export FreeDep
+func FreeDep(handle uint64) (res C.int) {
+    defer recoverError(handle, &res)
+    getInstance(handle).handler.Close()
+    instancesLock.Lock()
+    defer instancesLock.Unlock()
+    delete(instances, handle)
+    if prev, ok := lastErrors[handle]; ok {
+        C.free(unsafe.Pointer(prev))
+        delete(lastErrors, handle)
+    }
+    return depOK
 }
 
 //export StartTransactionRecording
 func StartTransactionRecording(
+    handle uint64,
     isCreate bool, addr C.Address, input C.SizedArray, block uint64,
     timestamp uint64, origin C.Address, txHash C.Hash,
     code C.SizedArray, isSelfdestruct6780, isRandom bool,
-) {
-    cTracing = true
+) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    // callbacks are called from go, so missing ones would crash the host process
+    if instance.getNonce == nil || instance.getCode == nil {
+        panic("get_nonce and get_code callbacks are not registered")
+    }
     block0 := new(big.Int)
     block0.SetUint64(block)
-    cDepHandler.StartTransactionRecording(
+    if !instance.handler.ShouldRecordTransaction(unpackAddress(addr), unpackAddress(origin), block0) {
+        return depOK
+    }
+    instance.tracing = true
+    instance.handler.StartTransactionRecording(
         isCreate, unpackAddress(addr), unpackSizedArray(input), block0,
         timestamp, unpackAddress(origin), unpackHash(txHash),
-        unpackSizedArray(code), isSelfdestruct6780, isRandom, StateDBC{},
+        unpackSizedArray(code), isSelfdestruct6780, isRandom, StateDBC{instance},
     )
+    return depOK
+}
+
+//export HandleAuthorization
+func HandleAuthorization(handle uint64, authority C.Address, delegate C.Address) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    if !instance.tracing {
+        return depOK
+    }
+    instance.handler.HandleAuthorization(unpackAddress(authority), unpackAddress(delegate))
+    return depOK
 }
 
 //export EndTransactionRecording
-func EndTransactionRecording() {
-    if !cTracing {
-        return
+func EndTransactionRecording(handle uint64) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    if !instance.tracing {
+        return depOK
     }
-    cTracing = false
-    cDepHandler.EndTransactionRecording()
+    instance.tracing = false
+    instance.handler.EndTransactionRecording()
+    return depOK
 }
 
 //export HandleOpcode
 func HandleOpcode(
+    handle uint64,
     stack C.Stack, memory C.SizedArray, addr C.Address,
     pc uint64, op byte, isInvalid bool, hasError bool,
-) {
-    if !cTracing {
-        return
+) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    if !instance.tracing {
+        return depOK
     }
-    cDepHandler.HandleOpcode(
+    instance.handler.HandleOpcode(
         unpackStack(stack), unpackSizedArray(memory), unpackAddress(addr),
         pc, op, isInvalid, hasError,
     )
+    return depOK
 }
 
 //export HandleEnter
-func HandleEnter(to C.Address, input C.SizedArray) {
-    if !cTracing {
-        return
+func HandleEnter(handle uint64, to C.Address, input C.SizedArray) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    if !instance.tracing {
+        return depOK
     }
-    cDepHandler.HandleEnter(
+    instance.handler.HandleEnter(
         unpackAddress(to), unpackSizedArray(input),
     )
+    return depOK
 }
 
 //export HandleFault
-func HandleFault(op byte) {
-    if !cTracing {
-        return
+func HandleFault(handle uint64, op byte) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    if !instance.tracing {
+        return depOK
     }
-    cDepHandler.HandleFault(op)
+    instance.handler.HandleFault(op)
+    return depOK
 }
 
 //export HandleExit
-func HandleExit(output C.SizedArray, hasError bool) {
-    if !cTracing {
-        return
+func HandleExit(handle uint64, output C.SizedArray, hasError bool) (res C.int) {
+    defer recoverError(handle, &res)
+    instance := getInstance(handle)
+    if !instance.tracing {
+        return depOK
     }
-    cDepHandler.HandleExit(unpackSizedArray(output), hasError)
+    instance.handler.HandleExit(unpackSizedArray(output), hasError)
+    return depOK
 }